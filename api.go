@@ -2,6 +2,7 @@ package telebot
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"io/ioutil"
@@ -9,6 +10,7 @@ import (
 	"mime/multipart"
 	"net/http"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -16,18 +18,71 @@ import (
 	"github.com/pkg/errors"
 )
 
+// APIResponse is a response of any Bot API method.
+type APIResponse struct {
+	Ok          bool                `json:"ok"`
+	Result      json.RawMessage     `json:"result,omitempty"`
+	Code        int                 `json:"error_code,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Parameters  *ResponseParameters `json:"parameters,omitempty"`
+}
+
+// ResponseParameters describes why a request was unsuccessful.
+type ResponseParameters struct {
+	// MigrateTo is the identifier of the supergroup
+	// the group has been migrated to.
+	MigrateTo int64 `json:"migrate_to_chat_id,omitempty"`
+
+	// RetryAfter is the number of seconds left to wait
+	// before the request can be repeated.
+	RetryAfter int `json:"retry_after,omitempty"`
+}
+
 // Raw lets you call any method of Bot API manually.
 // It also handles API errors, so you only need to unwrap
 // result field from json data.
+//
+// The request is bound to the bot context, see WithContext.
 func (b *Bot) Raw(method string, payload interface{}) ([]byte, error) {
-	url := b.URL + "/bot" + b.Token + "/" + method
+	return b.RawContext(b.context(), method, payload)
+}
+
+// RawContext is like Raw, but the request is bound to the given
+// context, so it could be cancelled or given a deadline.
+func (b *Bot) RawContext(ctx context.Context, method string, payload interface{}) ([]byte, error) {
+	if replyInline(ctx, method, payload) {
+		data := []byte(`{"ok":true,"result":null}`)
+		b.observe(ctx, method, payload, nil, data, nil)
+		return data, nil
+	}
 
 	var buf bytes.Buffer
 	if err := json.NewEncoder(&buf).Encode(payload); err != nil {
 		return nil, err
 	}
 
-	resp, err := b.client.Post(url, "application/json", &buf)
+	data, err := b.withRetry(ctx, method, true, func() ([]byte, error) {
+		return b.raw(ctx, method, payload, buf.Bytes())
+	})
+
+	b.observe(ctx, method, payload, nil, data, err)
+	return data, err
+}
+
+func (b *Bot) raw(ctx context.Context, method string, payload interface{}, body []byte) ([]byte, error) {
+	if err := b.limit(ctx, method, payload); err != nil {
+		return nil, wrapError(err)
+	}
+
+	url := b.URL + "/bot" + b.Token + "/" + method
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.client.Do(req)
 	if err != nil {
 		return nil, wrapError(err)
 	}
@@ -45,6 +100,7 @@ func (b *Bot) Raw(method string, payload interface{}) ([]byte, error) {
 		body = bytes.ReplaceAll(body, []byte(`"{`), []byte(`{`))
 		body = bytes.ReplaceAll(body, []byte(`}"`), []byte(`}`))
 
+		var buf bytes.Buffer
 		indent := func(b []byte) string {
 			buf.Reset()
 			json.Indent(&buf, b, "", "\t")
@@ -57,7 +113,7 @@ func (b *Bot) Raw(method string, payload interface{}) ([]byte, error) {
 	}
 
 	// returning data as well
-	return data, extractOk(data)
+	return data, extractResponse(resp.StatusCode, data)
 }
 
 func (b *Bot) sendFiles(method string, files map[string]File, params map[string]string) ([]byte, error) {
@@ -81,6 +137,35 @@ func (b *Bot) sendFiles(method string, files map[string]File, params map[string]
 		return b.Raw(method, params)
 	}
 
+	// readers can't be read twice, while files on disk
+	// are opened again on every attempt
+	replayable := true
+	for _, file := range rawFiles {
+		if _, ok := file.(io.Reader); ok {
+			replayable = false
+		}
+	}
+
+	ctx := b.context()
+	data, err := b.withRetry(ctx, method, replayable, func() ([]byte, error) {
+		return b.sendMultipart(ctx, method, files, rawFiles, params)
+	})
+
+	uploaded := make([]string, 0, len(rawFiles))
+	for name := range rawFiles {
+		uploaded = append(uploaded, name)
+	}
+	sort.Strings(uploaded)
+
+	b.observe(ctx, method, params, uploaded, data, err)
+	return data, err
+}
+
+func (b *Bot) sendMultipart(ctx context.Context, method string, files map[string]File, rawFiles map[string]interface{}, params map[string]string) ([]byte, error) {
+	if err := b.limit(ctx, method, params); err != nil {
+		return nil, wrapError(err)
+	}
+
 	pipeReader, pipeWriter := io.Pipe()
 	writer := multipart.NewWriter(pipeWriter)
 
@@ -107,7 +192,15 @@ func (b *Bot) sendFiles(method string, files map[string]File, params map[string]
 
 	url := b.URL + "/bot" + b.Token + "/" + method
 
-	resp, err := b.client.Post(url, writer.FormDataContentType(), pipeReader)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, pipeReader)
+	if err != nil {
+		err = wrapError(err)
+		pipeReader.CloseWithError(err)
+		return nil, err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := b.client.Do(req)
 	if err != nil {
 		err = wrapError(err)
 		pipeReader.CloseWithError(err)
@@ -125,7 +218,7 @@ func (b *Bot) sendFiles(method string, files map[string]File, params map[string]
 		return nil, wrapError(err)
 	}
 
-	return data, extractOk(data)
+	return data, extractResponse(resp.StatusCode, data)
 }
 
 func addFileToWriter(writer *multipart.Writer, filename, field string, file interface{}) error {
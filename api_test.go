@@ -1,6 +1,7 @@
 package telebot
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
@@ -8,8 +9,10 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // testPayload implements json.Marshaler
@@ -70,5 +73,63 @@ func TestRaw(t *testing.T) {
 	assert.EqualError(t, err, "telebot: "+io.ErrUnexpectedEOF.Error())
 
 	_, err = b.Raw("testUnknownError", nil)
-	assert.EqualError(t, err, "telegram unknown: unknown error (400)")
+	assert.EqualError(t, err, "telegram: unknown error (400)")
+}
+
+func TestRawContext(t *testing.T) {
+	done := make(chan struct{})
+
+	// hangs until the client gives up
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	b, err := NewBot(Settings{URL: srv.URL, Client: srv.Client(), offline: true})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err = b.RawContext(ctx, "getMe", nil)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	view := b.WithContext(ctx)
+	assert.Equal(t, b.handlers, view.handlers)
+	assert.Equal(t, b.state, view.state)
+
+	_, err = view.Send(&Chat{ID: 1}, "text")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// multipart request
+	photo := &Photo{File: FromReader(strings.NewReader("photo"))}
+	_, err = view.Send(&Chat{ID: 1}, photo)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	_, err = view.GetFile(&File{FileID: "file"})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	t.Run("LongPoller", func(t *testing.T) {
+		stop := make(chan struct{})
+		polled := make(chan struct{})
+
+		go func() {
+			p := &LongPoller{Timeout: time.Minute}
+			p.Poll(b, make(chan Update), stop)
+			close(polled)
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+		close(stop)
+
+		select {
+		case <-polled:
+		case <-time.After(time.Second):
+			t.Fatal("poller didn't cancel the pending request")
+		}
+	})
 }
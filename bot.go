@@ -1,14 +1,18 @@
 package telebot
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/pkg/errors"
 )
@@ -28,6 +32,12 @@ func NewBot(pref Settings) (*Bot, error) {
 	if pref.URL == "" {
 		pref.URL = DefaultApiURL
 	}
+	if pref.Storage == nil {
+		pref.Storage = NewMemoryStorage(10000)
+	}
+	if pref.Ordering != OrderNone && pref.Workers == 0 {
+		pref.Workers = runtime.NumCPU()
+	}
 
 	bot := &Bot{
 		Token:   pref.Token,
@@ -36,13 +46,26 @@ func NewBot(pref Settings) (*Bot, error) {
 		Poller:  pref.Poller,
 		Content: pref.Content,
 
-		handlers:    make(map[string]interface{}),
+		handlers:    make(map[string]HandlerFunc),
 		synchronous: pref.Synchronous,
 		verbose:     pref.Verbose,
 		parseMode:   pref.ParseMode,
-		stop:        make(chan struct{}),
+		stop:        make(chan chan struct{}),
 		reporter:    pref.Reporter,
+		onError:     pref.OnError,
+		retry:       pref.Retry,
+		limiter:     pref.Limiter,
+		dialogs:     &dialogs{},
+		commands:    &commands{},
+		routes:      &textRoutes{},
+		syncCmds:    pref.SyncCommands,
+		storage:     pref.Storage,
 		client:      client,
+		state:       &botState{},
+	}
+
+	if pref.Workers > 0 {
+		bot.workers = newWorkerPool(pref.Workers, pref.Updates, pref.Ordering)
 	}
 
 	if pref.offline {
@@ -67,13 +90,53 @@ type Bot struct {
 	Poller  Poller
 	*Content
 
-	handlers    map[string]interface{}
+	handlers    map[string]HandlerFunc
+	middleware  []MiddlewareFunc
 	synchronous bool
+	workers     *workerPool
 	verbose     bool
 	parseMode   ParseMode
 	reporter    func(error)
-	stop        chan struct{}
+	onError     func(error, Context)
+	retry       *RetryPolicy
+	limiter     Limiter
+	dialogs     *dialogs
+	commands    *commands
+	routes      *textRoutes
+	syncCmds    bool
+	storage     Storage
+	stop        chan chan struct{}
 	client      *http.Client
+
+	ctx   context.Context
+	state *botState
+}
+
+// botState is shared between the bot and its views
+// created with WithContext.
+type botState struct {
+	lastUpdateID int64 // first for the atomic alignment
+
+	mu       sync.Mutex
+	running  bool
+	finished chan struct{}
+	pollErr  error
+	inflight int
+	idle     chan struct{}
+
+	// deadline of the poller to stop, see Shutdown
+	shutdownCtx context.Context
+
+	// updates tracked by the poller, see track
+	pending map[int]int
+	waiters map[int]chan struct{}
+	handled chan struct{}
+
+	// notified of the calls, see RecordPoller
+	observer *callObserver
+
+	// replies of the updates received with Webhook.Reply
+	replies map[int]*webhookReply
 }
 
 // Update object represents an incoming update.
@@ -106,24 +169,35 @@ type Command struct {
 // Handle lets you set the handler for some command name or
 // one of the supported endpoints.
 //
+// The handler could be func(tb.Context) error, which fits every
+// endpoint, or one of the endpoint-specific signatures.
+//
 // Example:
 //
-//     b.Handle("/help", func (m *tb.Message) {})
+//     b.Handle("/help", func (c tb.Context) error { return nil })
 //     b.Handle(tb.OnText, func (m *tb.Message) {})
 //     b.Handle(tb.OnQuery, func (q *tb.Query) {})
 //
 //     // make a hook for one of your preserved (by-pointer) inline buttons.
 //     b.Handle(&inlineButton, func (c *tb.Callback) {})
 //
-func (b *Bot) Handle(endpoint interface{}, handler interface{}) {
-	switch end := endpoint.(type) {
+// Optional middleware are applied only to this endpoint, after the
+// global ones added with Use:
+//
+//     b.Handle("/ban", onBan, adminOnly)
+//
+func (b *Bot) Handle(endpoint interface{}, handler interface{}, m ...MiddlewareFunc) {
+	var end string
+	switch e := endpoint.(type) {
 	case string:
-		b.handlers[end] = handler
+		end = e
 	case CallbackEndpoint:
-		b.handlers[end.CallbackUnique()] = handler
+		end = e.CallbackUnique()
 	default:
 		panic("telebot: unsupported endpoint")
 	}
+
+	b.handlers[end] = applyMiddleware(wrapHandler(handler), m...)
 }
 
 var (
@@ -133,145 +207,470 @@ var (
 
 // Start brings bot into motion by consuming incoming
 // updates (see Bot.Updates channel).
-func (b *Bot) Start() {
+//
+// It blocks until the bot is stopped, or the poller fails
+// with an error it can't recover from, like ErrUnauthorized.
+// In the latter case, the error is returned.
+func (b *Bot) Start() error {
 	if b.Poller == nil {
 		panic("telebot: can't start without a poller")
 	}
 
+	finished := make(chan struct{})
+
+	b.state.mu.Lock()
+	b.state.running = true
+	b.state.finished = finished
+	b.state.pollErr = nil
+	b.state.shutdownCtx = nil
+	b.state.mu.Unlock()
+
+	defer func() {
+		b.state.mu.Lock()
+		b.state.running = false
+		b.state.mu.Unlock()
+		close(finished)
+	}()
+
+	if b.syncCmds {
+		if err := b.SyncCommands(); err != nil {
+			b.debug(err)
+		}
+	}
+
 	stop := make(chan struct{})
-	go b.Poller.Poll(b, b.Updates, stop)
+	polled := make(chan struct{})
+
+	go func() {
+		b.Poller.Poll(b, b.Updates, stop)
+		close(polled)
+	}()
 
+	done := polled
 	for {
 		select {
 		// handle incoming updates
 		case upd := <-b.Updates:
 			b.ProcessUpdate(upd)
+		// poller has returned by itself
+		case <-done:
+			done = nil
+			if err := b.pollerError(); err != nil {
+				close(stop)
+				b.drain(polled)
+				return err
+			}
 		// call to stop polling
-		case <-b.stop:
+		case stopped := <-b.stop:
 			close(stop)
-			return
+			b.drain(polled)
+			close(stopped)
+			return nil
+		}
+	}
+}
+
+// pollerFailed records the fatal error of the poller,
+// which is returned by Start once the poller returns.
+func (b *Bot) pollerFailed(err error) {
+	b.state.mu.Lock()
+	b.state.pollErr = err
+	b.state.mu.Unlock()
+}
+
+func (b *Bot) pollerError() error {
+	b.state.mu.Lock()
+	defer b.state.mu.Unlock()
+	return b.state.pollErr
+}
+
+// drain keeps handling incoming updates until the poller is done,
+// then flushes the ones left in the Updates channel.
+func (b *Bot) drain(polled chan struct{}) {
+	for {
+		select {
+		case upd := <-b.Updates:
+			b.ProcessUpdate(upd)
+		case <-polled:
+			for {
+				select {
+				case upd := <-b.Updates:
+					b.ProcessUpdate(upd)
+				default:
+					return
+				}
+			}
 		}
 	}
 }
 
-// Stop gracefully shuts the poller down.
+// Stop gracefully shuts the poller down. It doesn't wait
+// for the poller to stop, use Shutdown for that purpose.
 func (b *Bot) Stop() {
-	b.stop <- struct{}{}
+	b.stop <- make(chan struct{})
+}
+
+// Shutdown gracefully stops the bot. It stops the poller, handles
+// the updates left in the Updates channel and waits for all the
+// running handlers to finish. Then the workers exit, if the bot
+// has any, until the next update is processed.
+//
+// Returns the ID of the last processed update, so the next start
+// could resume from it. If the context expires first, returns
+// its error, and the handlers may still be running. The context
+// bounds the shutdown of the poller as well, e.g. of the webhook
+// server, so Start returns by then.
+//
+// Example:
+//
+//		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+//		defer cancel()
+//
+//		lastID, err := b.Shutdown(ctx)
+//
+func (b *Bot) Shutdown(ctx context.Context) (int, error) {
+	b.state.mu.Lock()
+	running, finished := b.state.running, b.state.finished
+	if running {
+		b.state.shutdownCtx = ctx
+	}
+	b.state.mu.Unlock()
+
+	if running {
+		stopped := make(chan struct{})
+		select {
+		case b.stop <- stopped:
+		case <-finished:
+			// stopped on its own, by the poller error
+			close(stopped)
+		case <-ctx.Done():
+			return b.LastUpdateID(), ctx.Err()
+		}
+
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			return b.LastUpdateID(), ctx.Err()
+		}
+	}
+
+	b.state.mu.Lock()
+	if b.state.inflight == 0 {
+		b.state.mu.Unlock()
+		b.stopWorkers()
+		return b.LastUpdateID(), nil
+	}
+	if b.state.idle == nil {
+		b.state.idle = make(chan struct{})
+	}
+	idle := b.state.idle
+	b.state.mu.Unlock()
+
+	select {
+	case <-idle:
+		b.stopWorkers()
+		return b.LastUpdateID(), nil
+	case <-ctx.Done():
+		return b.LastUpdateID(), ctx.Err()
+	}
+}
+
+// stopWorkers stops the worker pool, if there is such. It's
+// called once the handlers are done, so the queues are empty.
+func (b *Bot) stopWorkers() {
+	if b.workers != nil {
+		b.workers.stop()
+	}
+}
+
+// shutdownContext returns the context passed to Shutdown,
+// which the poller should stop within. If the bot is stopped
+// with Stop, there is no deadline.
+func (b *Bot) shutdownContext() context.Context {
+	b.state.mu.Lock()
+	defer b.state.mu.Unlock()
+
+	if b.state.shutdownCtx != nil {
+		return b.state.shutdownCtx
+	}
+	return context.Background()
+}
+
+func (b *Bot) handlerStarted(id int) {
+	b.state.mu.Lock()
+	b.state.inflight++
+	if n, ok := b.state.pending[id]; ok {
+		b.state.pending[id] = n + 1
+	}
+	b.state.mu.Unlock()
+}
+
+func (b *Bot) handlerDone(id int) {
+	b.state.mu.Lock()
+	b.state.inflight--
+	if b.state.inflight == 0 && b.state.idle != nil {
+		close(b.state.idle)
+		b.state.idle = nil
+	}
+	b.untrackLocked(id)
+	b.state.mu.Unlock()
+}
+
+// track marks the update as pending until it's processed
+// and all the handlers it has started are done. The returned
+// channel is closed then.
+func (b *Bot) track(id int) <-chan struct{} {
+	b.state.mu.Lock()
+	defer b.state.mu.Unlock()
+
+	if b.state.pending == nil {
+		b.state.pending = make(map[int]int)
+		b.state.waiters = make(map[int]chan struct{})
+	}
+	b.state.pending[id]++
+
+	done, ok := b.state.waiters[id]
+	if !ok {
+		done = make(chan struct{})
+		b.state.waiters[id] = done
+	}
+	return done
+}
+
+func (b *Bot) untrack(id int) {
+	b.state.mu.Lock()
+	b.untrackLocked(id)
+	b.state.mu.Unlock()
+}
+
+func (b *Bot) untrackLocked(id int) {
+	n, ok := b.state.pending[id]
+	if !ok {
+		return
+	}
+
+	if n > 1 {
+		b.state.pending[id] = n - 1
+		return
+	}
+
+	delete(b.state.pending, id)
+	close(b.state.waiters[id])
+	delete(b.state.waiters, id)
+
+	if len(b.state.pending) == 0 && b.state.handled != nil {
+		close(b.state.handled)
+		b.state.handled = nil
+	}
+}
+
+// waitHandled blocks until all the tracked updates are
+// handled. It returns false if stop is closed earlier.
+func (b *Bot) waitHandled(stop chan struct{}) bool {
+	b.state.mu.Lock()
+	if len(b.state.pending) == 0 {
+		b.state.mu.Unlock()
+		return true
+	}
+	if b.state.handled == nil {
+		b.state.handled = make(chan struct{})
+	}
+	handled := b.state.handled
+	b.state.mu.Unlock()
+
+	select {
+	case <-handled:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+// WithContext returns a view of the bot, which binds all the Bot API
+// requests to the given context. The view shares handlers and state
+// with the original bot, and is meant to be used for outgoing calls:
+//
+//		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//		defer cancel()
+//
+//		_, err := b.WithContext(ctx).Send(chat, "Hello!")
+//
+func (b *Bot) WithContext(ctx context.Context) *Bot {
+	if ctx == nil {
+		panic("telebot: nil context")
+	}
+
+	view := *b
+	view.ctx = ctx
+	return &view
+}
+
+// context returns the context requests are bound to.
+func (b *Bot) context() context.Context {
+	if b.ctx == nil {
+		return context.Background()
+	}
+	return b.ctx
+}
+
+// Storage returns the storage the sessions are kept in.
+func (b *Bot) Storage() Storage {
+	return b.storage
+}
+
+// LastUpdateID returns the ID of the latest update
+// passed to ProcessUpdate.
+func (b *Bot) LastUpdateID() int {
+	return int(atomic.LoadInt64(&b.state.lastUpdateID))
+}
+
+func (b *Bot) storeUpdateID(id int) {
+	for {
+		last := atomic.LoadInt64(&b.state.lastUpdateID)
+		if int64(id) <= last || atomic.CompareAndSwapInt64(&b.state.lastUpdateID, last, int64(id)) {
+			return
+		}
+	}
 }
 
 // ProcessUpdate processes a single incoming update.
 // A started bot calls this function automatically.
 func (b *Bot) ProcessUpdate(upd Update) {
+	b.storeUpdateID(upd.ID)
+	defer b.untrack(upd.ID)
+
+	b = b.replyUpdate(upd)
+	b = b.observeUpdate(upd)
+	b.dispatch(upd, false)
+}
+
+// dispatch routes the update to its handlers. The queued
+// updates have waited for the conversation, see converse.
+func (b *Bot) dispatch(upd Update, queued bool) {
+	c := b.NewContext(upd)
+
 	if upd.Message != nil {
 		m := upd.Message
 
 		if m.PinnedMessage != nil {
-			b.handle(OnPinned, m)
+			b.handle(OnPinned, c)
 			return
 		}
 
-		// Commands
+		var match [][]string
 		if m.Text != "" {
 			// Filtering malicious messages
 			if m.Text[0] == '\a' {
 				return
 			}
 
-			match := cmdRx.FindAllStringSubmatch(m.Text, -1)
+			// Syntax: "</command>@<bot> <payload>"
+			match = cmdRx.FindAllStringSubmatch(m.Text, -1)
 			if match != nil {
-				// Syntax: "</command>@<bot> <payload>"
-
-				command, botName := match[0][1], match[0][3]
+				botName := match[0][3]
 				if botName != "" && !strings.EqualFold(b.Me.Username, botName) {
 					return
 				}
-
 				m.Payload = match[0][5]
-				if b.handle(command, m) {
+			}
+		}
+
+		// Conversations
+		if b.converse(c, queued) {
+			return
+		}
+
+		// Commands
+		if m.Text != "" {
+			if match != nil {
+				command := match[0][1]
+				if b.handle(command, c) {
+					return
+				}
+				if b.routeCommand(command, c) {
 					return
 				}
 			}
 
 			// 1:1 satisfaction
-			if b.handle(m.Text, m) {
+			if b.handle(m.Text, c) {
+				return
+			}
+
+			if b.routeText(c) {
 				return
 			}
 
-			b.handle(OnText, m)
+			b.handle(OnText, c)
 			return
 		}
 
-		if b.handleMedia(m) {
+		if b.handleMedia(c) {
 			return
 		}
 
 		if m.Invoice != nil {
-			b.handle(OnInvoice, m)
+			b.handle(OnInvoice, c)
 			return
 		}
 
 		if m.Payment != nil {
-			b.handle(OnPayment, m)
+			b.handle(OnPayment, c)
 			return
 		}
 
 		wasAdded := (m.UserJoined != nil && m.UserJoined.ID == b.Me.ID) ||
 			(m.UsersJoined != nil && isUserInList(b.Me, m.UsersJoined))
 		if m.GroupCreated || m.SuperGroupCreated || wasAdded {
-			b.handle(OnAddedToGroup, m)
+			b.handle(OnAddedToGroup, c)
 			return
 		}
 
 		if m.UserJoined != nil {
-			b.handle(OnUserJoined, m)
+			b.handle(OnUserJoined, c)
 			return
 		}
 
 		if m.UsersJoined != nil {
 			for _, user := range m.UsersJoined {
-				m.UserJoined = &user
-				b.handle(OnUserJoined, m)
+				// every handler gets its own copy of the message
+				joined, user := *m, user
+				joined.UserJoined = &user
+
+				upd.Message = &joined
+				b.handle(OnUserJoined, b.NewContext(upd))
 			}
 			return
 		}
 
 		if m.UserLeft != nil {
-			b.handle(OnUserLeft, m)
+			b.handle(OnUserLeft, c)
 			return
 		}
 
 		if m.NewGroupTitle != "" {
-			b.handle(OnNewGroupTitle, m)
+			b.handle(OnNewGroupTitle, c)
 			return
 		}
 
 		if m.NewGroupPhoto != nil {
-			b.handle(OnNewGroupPhoto, m)
+			b.handle(OnNewGroupPhoto, c)
 			return
 		}
 
 		if m.GroupPhotoDeleted {
-			b.handle(OnGroupPhotoDeleted, m)
+			b.handle(OnGroupPhotoDeleted, c)
 			return
 		}
 
 		if m.MigrateTo != 0 {
-			if handler, ok := b.handlers[OnMigration]; ok {
-				handler, ok := handler.(func(int64, int64))
-				if !ok {
-					panic("telebot: migration handler is bad")
-				}
-
-				b.runHandler(func() { handler(m.Chat.ID, m.MigrateTo) })
-			}
-
+			b.handle(OnMigration, c)
 			return
 		}
-
 	}
 
 	if upd.EditedMessage != nil {
-		b.handle(OnEdited, upd.EditedMessage)
+		b.handle(OnEdited, c)
 		return
 	}
 
@@ -279,16 +678,16 @@ func (b *Bot) ProcessUpdate(upd Update) {
 		m := upd.ChannelPost
 
 		if m.PinnedMessage != nil {
-			b.handle(OnPinned, m)
+			b.handle(OnPinned, c)
 			return
 		}
 
-		b.handle(OnChannelPost, upd.ChannelPost)
+		b.handle(OnChannelPost, c)
 		return
 	}
 
 	if upd.EditedChannelPost != nil {
-		b.handle(OnEditedChannelPost, upd.EditedChannelPost)
+		b.handle(OnEditedChannelPost, c)
 		return
 	}
 
@@ -309,152 +708,85 @@ func (b *Bot) ProcessUpdate(upd Update) {
 					unique, payload := match[0][1], match[0][3]
 
 					if handler, ok := b.handlers["\f"+unique]; ok {
-						handler, ok := handler.(func(*Callback))
-						if !ok {
-							panic(fmt.Errorf("telebot: %s callback handler is bad", unique))
-						}
-
 						upd.Callback.Data = payload
-						b.runHandler(func() { handler(upd.Callback) })
-
+						b.runHandler(handler, c)
 						return
 					}
 				}
 			}
 		}
 
-		if handler, ok := b.handlers[OnCallback]; ok {
-			handler, ok := handler.(func(*Callback))
-			if !ok {
-				panic("telebot: callback handler is bad")
-			}
-
-			b.runHandler(func() { handler(upd.Callback) })
-		}
-
+		b.handle(OnCallback, c)
 		return
 	}
 
 	if upd.Query != nil {
-		if handler, ok := b.handlers[OnQuery]; ok {
-			handler, ok := handler.(func(*Query))
-			if !ok {
-				panic("telebot: query handler is bad")
-			}
-
-			b.runHandler(func() { handler(upd.Query) })
-		}
-
+		b.handle(OnQuery, c)
 		return
 	}
 
 	if upd.ChosenInlineResult != nil {
-		if handler, ok := b.handlers[OnChosenInlineResult]; ok {
-			handler, ok := handler.(func(*ChosenInlineResult))
-			if !ok {
-				panic("telebot: chosen inline result handler is bad")
-			}
-
-			b.runHandler(func() { handler(upd.ChosenInlineResult) })
-		}
-
+		b.handle(OnChosenInlineResult, c)
 		return
 	}
 
 	if upd.ShippingQuery != nil {
-		if handler, ok := b.handlers[OnShipping]; ok {
-			handler, ok := handler.(func(*ShippingQuery))
-			if !ok {
-				panic("telebot: shipping query handler is bad")
-			}
-
-			b.runHandler(func() { handler(upd.ShippingQuery) })
-		}
-
+		b.handle(OnShipping, c)
 		return
 	}
 
 	if upd.PreCheckoutQuery != nil {
-		if handler, ok := b.handlers[OnCheckout]; ok {
-			handler, ok := handler.(func(*PreCheckoutQuery))
-			if !ok {
-				panic("telebot: pre checkout query handler is bad")
-			}
-
-			b.runHandler(func() { handler(upd.PreCheckoutQuery) })
-		}
-
+		b.handle(OnCheckout, c)
 		return
 	}
 
 	if upd.Poll != nil {
-		if handler, ok := b.handlers[OnPoll]; ok {
-			handler, ok := handler.(func(*Poll))
-			if !ok {
-				panic("telebot: poll handler is bad")
-			}
-
-			b.runHandler(func() { handler(upd.Poll) })
-		}
-
+		b.handle(OnPoll, c)
 		return
 	}
 
 	if upd.PollAnswer != nil {
-		if handler, ok := b.handlers[OnPollAnswer]; ok {
-			handler, ok := handler.(func(*PollAnswer))
-			if !ok {
-				panic("telebot: poll answer handler is bad")
-			}
-
-			b.runHandler(func() { handler(upd.PollAnswer) })
-		}
-
+		b.handle(OnPollAnswer, c)
 		return
 	}
 }
 
-func (b *Bot) handle(end string, m *Message) bool {
+func (b *Bot) handle(end string, c Context) bool {
 	if handler, ok := b.handlers[end]; ok {
-		handler, ok := handler.(func(*Message))
-		if !ok {
-			panic(fmt.Errorf("telebot: %s handler is bad", end))
-		}
-
-		b.runHandler(func() { handler(m) })
-
+		b.runHandler(handler, c)
 		return true
 	}
-
 	return false
 }
 
-func (b *Bot) handleMedia(m *Message) bool {
+func (b *Bot) handleMedia(c Context) bool {
+	m := c.Message()
+
 	switch {
 	case m.Photo != nil:
-		b.handle(OnPhoto, m)
+		b.handle(OnPhoto, c)
 	case m.Voice != nil:
-		b.handle(OnVoice, m)
+		b.handle(OnVoice, c)
 	case m.Audio != nil:
-		b.handle(OnAudio, m)
+		b.handle(OnAudio, c)
 	case m.Animation != nil:
-		b.handle(OnAnimation, m)
+		b.handle(OnAnimation, c)
 	case m.Document != nil:
-		b.handle(OnDocument, m)
+		b.handle(OnDocument, c)
 	case m.Sticker != nil:
-		b.handle(OnSticker, m)
+		b.handle(OnSticker, c)
 	case m.Video != nil:
-		b.handle(OnVideo, m)
+		b.handle(OnVideo, c)
 	case m.VideoNote != nil:
-		b.handle(OnVideoNote, m)
+		b.handle(OnVideoNote, c)
 	case m.Contact != nil:
-		b.handle(OnContact, m)
+		b.handle(OnContact, c)
 	case m.Location != nil:
-		b.handle(OnLocation, m)
+		b.handle(OnLocation, c)
 	case m.Venue != nil:
-		b.handle(OnVenue, m)
+		b.handle(OnVenue, c)
 	case m.Dice != nil:
-		b.handle(OnDice, m)
+		b.handle(OnDice, c)
 	default:
 		return false
 	}
@@ -1125,7 +1457,7 @@ func (b *Bot) GetFile(file *File) (io.ReadCloser, error) {
 	url := b.URL + "/file/bot" + b.Token + "/" + f.FilePath
 	file.FilePath = f.FilePath // saving file path
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(b.context(), http.MethodGet, url, nil)
 	if err != nil {
 		return nil, wrapError(err)
 	}
@@ -1,10 +1,13 @@
 package telebot
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -478,3 +481,86 @@ func TestBot(t *testing.T) {
 		assert.Equal(t, orig, cmds)
 	})
 }
+
+func TestBotOnError(t *testing.T) {
+	var (
+		gotErr error
+		gotUpd Update
+	)
+
+	b, err := NewBot(Settings{
+		Synchronous: true,
+		offline:     true,
+		OnError: func(err error, c Context) {
+			gotErr, gotUpd = err, c.Update()
+		},
+	})
+	require.NoError(t, err)
+
+	b.Handle("/start", func(m *Message) error {
+		return ErrBlockedByUser
+	})
+	b.Handle(OnCallback, func(c Context) error {
+		return FloodError{APIError: NewAPIError(429, "Too Many Requests"), RetryAfter: 8}
+	})
+	b.Handle(OnQuery, func(q *Query) error {
+		return nil
+	})
+
+	upd := Update{ID: 1, Message: &Message{Text: "/start"}}
+	b.ProcessUpdate(upd)
+	assert.Equal(t, ErrBlockedByUser, gotErr)
+	assert.Equal(t, upd, gotUpd)
+
+	b.ProcessUpdate(Update{ID: 2, Callback: &Callback{Data: "data"}})
+	var flood FloodError
+	require.True(t, errors.As(gotErr, &flood))
+	assert.Equal(t, 8, flood.RetryAfter)
+	assert.Equal(t, 2, gotUpd.ID)
+
+	gotErr = nil
+	b.ProcessUpdate(Update{ID: 3, Query: &Query{}})
+	assert.NoError(t, gotErr)
+}
+
+func TestBotShutdown(t *testing.T) {
+	tp := newTestPoller()
+
+	b, err := NewBot(Settings{Poller: tp, offline: true})
+	require.NoError(t, err)
+
+	var handled int32
+	b.Handle(OnText, func(m *Message) {
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&handled, 1)
+	})
+
+	for i := 1; i <= 5; i++ {
+		b.Updates <- Update{ID: i, Message: &Message{Text: "text"}}
+	}
+
+	go b.Start()
+	require.Eventually(t, func() bool {
+		b.state.mu.Lock()
+		defer b.state.mu.Unlock()
+		return b.state.running
+	}, time.Second, time.Millisecond)
+
+	lastID, err := b.Shutdown(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 5, lastID)
+	assert.Equal(t, int32(5), atomic.LoadInt32(&handled))
+	assert.Empty(t, b.Updates)
+
+	b.Handle(OnText, func(m *Message) {
+		time.Sleep(time.Second)
+	})
+	b.ProcessUpdate(Update{ID: 6, Message: &Message{Text: "text"}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	lastID, err = b.Shutdown(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, 6, lastID)
+}
@@ -0,0 +1,556 @@
+package telebot
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ArgType is a type of the command argument or flag.
+type ArgType int
+
+const (
+	// ArgString is a single word or a quoted string.
+	ArgString ArgType = iota
+
+	// ArgInt is an integer.
+	ArgInt
+
+	// ArgFloat is a floating-point number.
+	ArgFloat
+
+	// ArgBool is true, false, yes, no, on, off, 1 or 0.
+	// Flags of this type take no value.
+	ArgBool
+
+	// ArgText is the rest of the text as is, including the line
+	// breaks and the words looking like flags. Only the last
+	// positional argument can be such.
+	ArgText
+)
+
+func (t ArgType) String() string {
+	switch t {
+	case ArgInt:
+		return "integer"
+	case ArgFloat:
+		return "number"
+	case ArgBool:
+		return "boolean"
+	case ArgText:
+		return "text"
+	default:
+		return "string"
+	}
+}
+
+// Arg is a positional argument of the command.
+type Arg struct {
+	Name string
+	Type ArgType
+
+	// Optional arguments can be omitted. They
+	// can't be followed by the required ones.
+	Optional bool
+}
+
+// Flag is a named argument of the command, passed as --name
+// or --name=value. The value of a non-boolean flag could also
+// be passed as the next word: --name value.
+type Flag struct {
+	Name string
+	Type ArgType
+}
+
+// Cmd describes a command with its arguments, which is parsed
+// before the handler is called. Commands are matched regardless
+// of the case, and also by their aliases. If the arguments are
+// invalid, the handler isn't called, and the user gets the error
+// with the usage of the command.
+//
+// Arguments are separated by spaces, and could be quoted with
+// double, single or typographic quotes. Flags may appear anywhere
+// before the text argument, a single -- stops parsing them.
+//
+// Example:
+//
+//		b.Command(&tb.Cmd{
+//			Name:        "ban",
+//			Aliases:     []string{"b"},
+//			Description: "Ban the user",
+//			Args: []tb.Arg{
+//				{Name: "user"},
+//				{Name: "days", Type: tb.ArgInt, Optional: true},
+//			},
+//			Flags: []tb.Flag{
+//				{Name: "silent", Type: tb.ArgBool},
+//			},
+//			Handler: func(c tb.Context) error {
+//				args := tb.CommandArgs(c)
+//				return ban(args.String("user"), args.Int("days"), args.Bool("silent"))
+//			},
+//		})
+//
+type Cmd struct {
+	// Name is the command without the slash, 1-32 characters:
+	// English letters, digits and underscores.
+	Name string
+
+	// Aliases are the other names of the command.
+	// They aren't shown in the command list.
+	Aliases []string
+
+	// Description is shown in the command list, see SyncCommands.
+	Description string
+
+	Args  []Arg
+	Flags []Flag
+
+	Handler HandlerFunc
+
+	// Middleware is applied to the handler, after the global
+	// ones. It runs before the arguments are checked.
+	Middleware []MiddlewareFunc
+
+	// OnBadArgs is called instead of the handler, when the
+	// arguments are invalid. The error is an *ArgsError.
+	// By default, the error is sent along with the usage.
+	OnBadArgs func(c Context, err error) error
+
+	// Hidden commands aren't shown in the command list.
+	Hidden bool
+}
+
+// Usage returns the syntax of the command, like
+// "/ban <user> [days] [--silent]".
+func (cmd *Cmd) Usage() string {
+	parts := []string{"/" + cmd.Name}
+
+	for _, arg := range cmd.Args {
+		name := arg.Name
+		if arg.Type == ArgText {
+			name += "..."
+		}
+		if arg.Optional {
+			parts = append(parts, "["+name+"]")
+		} else {
+			parts = append(parts, "<"+name+">")
+		}
+	}
+
+	for _, flag := range cmd.Flags {
+		if flag.Type == ArgBool {
+			parts = append(parts, "[--"+flag.Name+"]")
+		} else {
+			parts = append(parts, "[--"+flag.Name+"=<"+flag.Type.String()+">]")
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// ArgsError is returned when the command arguments are invalid.
+// Its message is meant to be shown to the user.
+type ArgsError struct {
+	// Arg is the name of the argument or flag, if any.
+	Arg    string
+	Reason string
+}
+
+func (err *ArgsError) Error() string {
+	return err.Reason
+}
+
+// CmdArgs holds the parsed arguments and flags of the command.
+// The getters return zero values for the missing ones.
+type CmdArgs struct {
+	values map[string]interface{}
+}
+
+// CommandArgs returns the arguments of the command being
+// handled, or empty arguments if it's not a Cmd handler.
+func CommandArgs(c Context) *CmdArgs {
+	if args, ok := c.Get(cmdArgsKey).(*CmdArgs); ok {
+		return args
+	}
+	return &CmdArgs{}
+}
+
+// Has reports whether the argument or flag has been passed.
+func (a *CmdArgs) Has(name string) bool {
+	_, ok := a.values[name]
+	return ok
+}
+
+// String returns the string or text argument.
+func (a *CmdArgs) String(name string) string {
+	v, _ := a.values[name].(string)
+	return v
+}
+
+// Int returns the integer argument.
+func (a *CmdArgs) Int(name string) int {
+	v, _ := a.values[name].(int)
+	return v
+}
+
+// Float returns the number argument.
+func (a *CmdArgs) Float(name string) float64 {
+	v, _ := a.values[name].(float64)
+	return v
+}
+
+// Bool returns the boolean argument or flag.
+func (a *CmdArgs) Bool(name string) bool {
+	v, _ := a.values[name].(bool)
+	return v
+}
+
+const cmdArgsKey = "telebot:args"
+
+var cmdNameRx = regexp.MustCompile(`^[a-zA-Z0-9_]{1,32}$`)
+
+// commands holds the registered Cmds by their
+// lowercase names and aliases.
+type commands struct {
+	mu     sync.RWMutex
+	byName map[string]*Cmd
+	list   []*Cmd
+}
+
+// Command registers the command. It panics if the command
+// is malformed, or its name is already taken.
+func (b *Bot) Command(cmd *Cmd) {
+	if cmd.Handler == nil {
+		panic("telebot: command " + cmd.Name + " has no handler")
+	}
+	validateArgs(cmd)
+
+	b.commands.mu.Lock()
+	defer b.commands.mu.Unlock()
+
+	if b.commands.byName == nil {
+		b.commands.byName = make(map[string]*Cmd)
+	}
+
+	names := append([]string{cmd.Name}, cmd.Aliases...)
+	for _, name := range names {
+		name = strings.TrimPrefix(name, "/")
+		if !cmdNameRx.MatchString(name) {
+			panic("telebot: bad command name " + name)
+		}
+		if _, ok := b.commands.byName[strings.ToLower(name)]; ok {
+			panic("telebot: command " + name + " is already registered")
+		}
+	}
+
+	cmd.Name = strings.TrimPrefix(cmd.Name, "/")
+	for _, name := range names {
+		b.commands.byName[strings.ToLower(strings.TrimPrefix(name, "/"))] = cmd
+	}
+	b.commands.list = append(b.commands.list, cmd)
+}
+
+func validateArgs(cmd *Cmd) {
+	optional := false
+	for i, arg := range cmd.Args {
+		if arg.Type == ArgText && i != len(cmd.Args)-1 {
+			panic("telebot: text argument " + arg.Name + " must be the last one")
+		}
+		if optional && !arg.Optional {
+			panic("telebot: required argument " + arg.Name + " follows the optional one")
+		}
+		optional = optional || arg.Optional
+	}
+	for _, flag := range cmd.Flags {
+		if flag.Type == ArgText {
+			panic("telebot: flag " + flag.Name + " can't be text")
+		}
+	}
+}
+
+// CommandList returns the registered commands, which
+// aren't hidden, in the format of SetCommands.
+func (b *Bot) CommandList() []Command {
+	b.commands.mu.RLock()
+	defer b.commands.mu.RUnlock()
+
+	var list []Command
+	for _, cmd := range b.commands.list {
+		if !cmd.Hidden {
+			list = append(list, Command{
+				Text:        strings.ToLower(cmd.Name),
+				Description: cmd.Description,
+			})
+		}
+	}
+	return list
+}
+
+// SyncCommands sets the bot's command list to the registered
+// commands. With Settings.SyncCommands, it's called on Start.
+func (b *Bot) SyncCommands() error {
+	return b.SetCommands(b.CommandList())
+}
+
+// routeCommand runs the Cmd registered for the command, if any.
+func (b *Bot) routeCommand(command string, c Context) bool {
+	b.commands.mu.RLock()
+	cmd, ok := b.commands.byName[strings.ToLower(strings.TrimPrefix(command, "/"))]
+	b.commands.mu.RUnlock()
+
+	if !ok {
+		return false
+	}
+
+	// unlike the payload, the arguments may span several lines
+	var payload string
+	if text := c.Message().Text; text != "" {
+		if i := strings.IndexFunc(text, unicode.IsSpace); i >= 0 {
+			payload = strings.TrimSpace(text[i:])
+		}
+	}
+
+	b.runHandler(applyMiddleware(func(c Context) error {
+		args, err := cmd.parse(payload)
+		if err != nil {
+			if cmd.OnBadArgs != nil {
+				return cmd.OnBadArgs(c, err)
+			}
+			return c.Send(err.Error() + "\nUsage: " + cmd.Usage())
+		}
+
+		c.Set(cmdArgsKey, args)
+		return cmd.Handler(c)
+	}, cmd.Middleware...), c)
+
+	return true
+}
+
+func (cmd *Cmd) flag(name string) (Flag, bool) {
+	for _, f := range cmd.Flags {
+		if strings.EqualFold(f.Name, name) {
+			return f, true
+		}
+	}
+	return Flag{}, false
+}
+
+// parse parses the payload according to the arguments of the command.
+func (cmd *Cmd) parse(payload string) (*CmdArgs, error) {
+	var (
+		args       = &CmdArgs{values: make(map[string]interface{})}
+		positional []string
+		flags      = true
+	)
+
+	next := func(i int) (argToken, int, error) {
+		return nextArg(payload, i)
+	}
+
+	for i := 0; ; {
+		tok, end, err := next(i)
+		if err != nil {
+			return nil, err
+		}
+		if end == i {
+			break
+		}
+
+		name, isFlag := flagName(tok)
+		if flags && isFlag {
+			i = end
+
+			if name == "" {
+				// "--" stops parsing the flags
+				flags = false
+				continue
+			}
+
+			var value *string
+			if j := strings.IndexByte(name, '='); j >= 0 {
+				v := name[j+1:]
+				name, value = name[:j], &v
+			}
+
+			flag, ok := cmd.flag(name)
+			if !ok {
+				return nil, &ArgsError{Arg: name, Reason: "unknown flag --" + name}
+			}
+
+			if value == nil {
+				if flag.Type == ArgBool {
+					args.values[flag.Name] = true
+					continue
+				}
+
+				tok, end, err := next(i)
+				if err != nil {
+					return nil, err
+				}
+				if end == i {
+					return nil, &ArgsError{Arg: flag.Name, Reason: "missing value of --" + flag.Name}
+				}
+				i, value = end, &tok.text
+			}
+
+			v, err := parseArg(flag.Name, flag.Type, *value)
+			if err != nil {
+				return nil, err
+			}
+			args.values[flag.Name] = v
+			continue
+		}
+
+		n := len(positional)
+		if n < len(cmd.Args) && cmd.Args[n].Type == ArgText {
+			// the rest is taken as is, unless it's a single quoted string
+			text := strings.TrimSpace(payload[tok.start:])
+			if tok.quoted && strings.TrimSpace(payload[end:]) == "" {
+				text = tok.text
+			}
+			args.values[cmd.Args[n].Name] = text
+			positional = append(positional, text)
+			break
+		}
+
+		if n >= len(cmd.Args) {
+			return nil, &ArgsError{Reason: "too many arguments"}
+		}
+
+		v, err := parseArg(cmd.Args[n].Name, cmd.Args[n].Type, tok.text)
+		if err != nil {
+			return nil, err
+		}
+		args.values[cmd.Args[n].Name] = v
+		positional = append(positional, tok.text)
+		i = end
+	}
+
+	for _, arg := range cmd.Args[len(positional):] {
+		if !arg.Optional {
+			return nil, &ArgsError{Arg: arg.Name, Reason: "missing argument <" + arg.Name + ">"}
+		}
+	}
+
+	return args, nil
+}
+
+// flagName returns the name of the flag with the value, if
+// the token is such. iOS replaces -- with an em dash, so it's
+// accepted as well.
+func flagName(tok argToken) (string, bool) {
+	if tok.quoted {
+		return "", false
+	}
+	for _, prefix := range []string{"--", "—"} {
+		if strings.HasPrefix(tok.text, prefix) {
+			return tok.text[len(prefix):], true
+		}
+	}
+	return "", false
+}
+
+func parseArg(name string, typ ArgType, s string) (interface{}, error) {
+	bad := func() error {
+		return &ArgsError{
+			Arg:    name,
+			Reason: fmt.Sprintf("invalid %s: %q is not a valid %s", name, s, typ),
+		}
+	}
+
+	switch typ {
+	case ArgInt:
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, bad()
+		}
+		return v, nil
+	case ArgFloat:
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, bad()
+		}
+		return v, nil
+	case ArgBool:
+		switch strings.ToLower(s) {
+		case "true", "yes", "on", "1":
+			return true, nil
+		case "false", "no", "off", "0":
+			return false, nil
+		}
+		return nil, bad()
+	default:
+		return s, nil
+	}
+}
+
+type argToken struct {
+	text   string
+	start  int
+	quoted bool
+}
+
+// quotes maps the opening quotes to the closing ones.
+var quotes = map[rune]rune{
+	'"':  '"',
+	'\'': '\'',
+	'“':  '”',
+	'«':  '»',
+}
+
+// nextArg returns the word or the quoted string starting at i or
+// after the spaces, and the offset following it. The offset is i,
+// if there are no more. Backslash escapes the closing quote inside
+// double quotes.
+func nextArg(s string, i int) (argToken, int, error) {
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		i += size
+	}
+	if i >= len(s) {
+		return argToken{}, len(s), nil
+	}
+
+	start := i
+	r, size := utf8.DecodeRuneInString(s[i:])
+
+	closing, quoted := quotes[r]
+	if !quoted {
+		end := strings.IndexFunc(s[i:], unicode.IsSpace)
+		if end < 0 {
+			end = len(s) - i
+		}
+		return argToken{text: s[i : i+end], start: start}, i + end, nil
+	}
+
+	i += size
+
+	var buf strings.Builder
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+
+		if r == '\\' && closing == '"' && i < len(s) {
+			next, n := utf8.DecodeRuneInString(s[i:])
+			if next == '"' || next == '\\' {
+				buf.WriteRune(next)
+				i += n
+				continue
+			}
+		}
+		if r == closing {
+			return argToken{text: buf.String(), start: start, quoted: true}, i, nil
+		}
+		buf.WriteRune(r)
+	}
+
+	return argToken{}, 0, &ArgsError{Reason: "unterminated quote"}
+}
@@ -0,0 +1,166 @@
+package telebot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCmdParse(t *testing.T) {
+	cmd := &Cmd{
+		Name: "ban",
+		Args: []Arg{
+			{Name: "user"},
+			{Name: "days", Type: ArgInt, Optional: true},
+			{Name: "reason", Type: ArgText, Optional: true},
+		},
+		Flags: []Flag{
+			{Name: "silent", Type: ArgBool},
+			{Name: "score", Type: ArgFloat},
+		},
+	}
+
+	tests := []struct {
+		payload string
+		want    map[string]interface{}
+		err     string
+	}{
+		{payload: "", err: "missing argument <user>"},
+		{payload: "bob", want: map[string]interface{}{"user": "bob"}},
+		{payload: "bob 3", want: map[string]interface{}{"user": "bob", "days": 3}},
+		{payload: "bob x", err: `invalid days: "x" is not a valid integer`},
+		{payload: `"bob smith" 3 spam and  --flood`, want: map[string]interface{}{
+			"user": "bob smith", "days": 3, "reason": "spam and  --flood",
+		}},
+		{payload: "bob 1 \"quoted text\"", want: map[string]interface{}{
+			"user": "bob", "days": 1, "reason": "quoted text",
+		}},
+		{payload: "bob 1 line\nbreak", want: map[string]interface{}{
+			"user": "bob", "days": 1, "reason": "line\nbreak",
+		}},
+		{payload: "--silent “bob smith” --score=1.5", want: map[string]interface{}{
+			"user": "bob smith", "silent": true, "score": 1.5,
+		}},
+		{payload: "bob —score 2 —silent", want: map[string]interface{}{
+			"user": "bob", "score": 2.0, "silent": true,
+		}},
+		{payload: `-- --silent`, want: map[string]interface{}{"user": "--silent"}},
+		{payload: `"bob \"the\" cat"`, want: map[string]interface{}{"user": `bob "the" cat`}},
+		{payload: "bob --score", err: "missing value of --score"},
+		{payload: "bob --loud", err: "unknown flag --loud"},
+		{payload: `"bob`, err: "unterminated quote"},
+		{payload: "bob --silent=maybe", err: `invalid silent: "maybe" is not a valid boolean`},
+	}
+
+	for _, tt := range tests {
+		args, err := cmd.parse(tt.payload)
+		if tt.err != "" {
+			assert.EqualError(t, err, tt.err, tt.payload)
+			continue
+		}
+		if assert.NoError(t, err, tt.payload) {
+			assert.Equal(t, tt.want, args.values, tt.payload)
+		}
+	}
+
+	_, err := (&Cmd{Name: "ping"}).parse("pong")
+	assert.EqualError(t, err, "too many arguments")
+
+	assert.Equal(t,
+		"/ban <user> [days] [reason...] [--silent] [--score=<number>]",
+		cmd.Usage())
+}
+
+func TestBotCommand(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls []map[string]string
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params map[string]string
+		json.NewDecoder(r.Body).Decode(&params)
+		params["method"] = r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+
+		mu.Lock()
+		calls = append(calls, params)
+		mu.Unlock()
+
+		w.Write([]byte(`{"ok":true,"result":true}`))
+	}))
+	defer srv.Close()
+
+	b, err := NewBot(Settings{
+		URL:         srv.URL,
+		Client:      srv.Client(),
+		Synchronous: true,
+		offline:     true,
+	})
+	require.NoError(t, err)
+	b.Me = &User{Username: "test_bot"}
+
+	var got []string
+	b.Command(&Cmd{
+		Name:        "ban",
+		Aliases:     []string{"b"},
+		Description: "Ban the user",
+		Args:        []Arg{{Name: "user"}},
+		Handler: func(c Context) error {
+			got = append(got, CommandArgs(c).String("user"))
+			return nil
+		},
+	})
+	b.Command(&Cmd{
+		Name:    "debug",
+		Hidden:  true,
+		Handler: func(c Context) error { return nil },
+	})
+
+	assert.Panics(t, func() {
+		b.Command(&Cmd{Name: "B", Handler: func(c Context) error { return nil }})
+	})
+	assert.Panics(t, func() {
+		b.Command(&Cmd{
+			Name:    "bad",
+			Args:    []Arg{{Name: "a", Optional: true}, {Name: "b"}},
+			Handler: func(c Context) error { return nil },
+		})
+	})
+
+	process := func(text string) {
+		b.ProcessUpdate(Update{Message: &Message{Text: text, Chat: &Chat{ID: 1}}})
+	}
+
+	process("/ban bob")
+	process("/BAN alice")
+	process("/b@Test_Bot carol")
+	process("/ban@other_bot dave")
+	process("/ban")
+
+	assert.Equal(t, []string{"bob", "alice", "carol"}, got)
+
+	require.Len(t, calls, 1)
+	assert.Equal(t, "sendMessage", calls[0]["method"])
+	assert.Equal(t, "missing argument <user>\nUsage: /ban <user>", calls[0]["text"])
+
+	// exact handlers take precedence
+	b.Handle("/ban", func(c Context) error {
+		got = append(got, "handle")
+		return nil
+	})
+	process("/ban eve")
+	assert.Equal(t, "handle", got[len(got)-1])
+
+	assert.Equal(t, []Command{{Text: "ban", Description: "Ban the user"}}, b.CommandList())
+
+	require.NoError(t, b.SyncCommands())
+	require.Len(t, calls, 2)
+	assert.Equal(t, "setMyCommands", calls[1]["method"])
+	assert.JSONEq(t, `[{"command":"ban","description":"Ban the user"}]`, calls[1]["commands"])
+}
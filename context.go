@@ -0,0 +1,401 @@
+package telebot
+
+import (
+	"strings"
+	"sync"
+)
+
+// Context wraps an update and represents the context of current event.
+// It is passed through the middleware chain to the handler.
+//
+// Context-based handlers have the same signature for every endpoint:
+//
+//		b.Handle("/start", func(c tb.Context) error {
+//			return c.Send("Hello, " + c.Sender().FirstName)
+//		})
+//
+type Context interface {
+	// Bot returns the bot instance.
+	Bot() *Bot
+
+	// Update returns the original update.
+	Update() Update
+
+	// Message returns stored message if such presented.
+	Message() *Message
+
+	// Callback returns stored callback if such presented.
+	Callback() *Callback
+
+	// Query returns stored query if such presented.
+	Query() *Query
+
+	// ChosenInlineResult returns stored inline result if such presented.
+	ChosenInlineResult() *ChosenInlineResult
+
+	// ShippingQuery returns stored shipping query if such presented.
+	ShippingQuery() *ShippingQuery
+
+	// PreCheckoutQuery returns stored pre checkout query if such presented.
+	PreCheckoutQuery() *PreCheckoutQuery
+
+	// Poll returns stored poll if such presented.
+	Poll() *Poll
+
+	// PollAnswer returns stored poll answer if such presented.
+	PollAnswer() *PollAnswer
+
+	// Sender returns the current recipient, depending on the context type.
+	// Returns nil if user is not presented.
+	Sender() *User
+
+	// Chat returns the current chat, depending on the context type.
+	// Returns nil if chat is not presented.
+	Chat() *Chat
+
+	// Recipient combines both Sender and Chat functions. If there is no user
+	// the chat will be returned. The native context cannot be without sender,
+	// but it is useful in the case when the context created intentionally
+	// by the NewContext constructor and have only Chat field inside.
+	Recipient() Recipient
+
+	// Text returns the message text, depending on the context type.
+	// In the case when no related data presented, returns an empty string.
+	Text() string
+
+	// Data returns the current data, depending on the context type.
+	// If the context contains command, returns its payload string.
+	// If the context contains callback, returns its data string.
+	// If the context contains inline query, returns its text.
+	// If the context contains shipping or pre checkout query,
+	// returns its payload.
+	Data() string
+
+	// Payload returns the command payload of the message.
+	Payload() string
+
+	// Args returns a raw slice of command or callback arguments as strings.
+	// The message arguments split by space, while the callback's ones by a "|" symbol.
+	Args() []string
+
+	// Send sends a message to the current recipient.
+	// See Send from bot.go.
+	Send(what interface{}, opts ...interface{}) error
+
+	// SendAlbum sends an album to the current recipient.
+	// See SendAlbum from bot.go.
+	SendAlbum(a Album, opts ...interface{}) error
+
+	// Reply replies to the current message.
+	// See Reply from bot.go.
+	Reply(what interface{}, opts ...interface{}) error
+
+	// Forward forwards the given message to the current recipient.
+	// See Forward from bot.go.
+	Forward(msg Editable, opts ...interface{}) error
+
+	// ForwardTo forwards the current message to the given recipient.
+	// See Forward from bot.go
+	ForwardTo(to Recipient, opts ...interface{}) error
+
+	// Edit edits the current message.
+	// See Edit from bot.go.
+	Edit(what interface{}, opts ...interface{}) error
+
+	// EditCaption edits the caption of the current message.
+	// See EditCaption from bot.go.
+	EditCaption(caption string, opts ...interface{}) error
+
+	// Delete removes the current message.
+	// See Delete from bot.go.
+	Delete() error
+
+	// Notify updates the chat action for the current recipient.
+	// See Notify from bot.go.
+	Notify(action ChatAction) error
+
+	// Respond sends a response for the current callback query.
+	// See Respond from bot.go.
+	Respond(resp ...*CallbackResponse) error
+
+	// Answer sends a response to the current inline query.
+	// See Answer from bot.go.
+	Answer(resp *QueryResponse) error
+
+	// Get retrieves data from the context.
+	Get(key string) interface{}
+
+	// Set saves data in the context.
+	Set(key string, val interface{})
+
+	// Session returns the session of the sender in the current chat,
+	// which is kept in the bot storage between the updates.
+	Session() Session
+}
+
+// NewContext returns a new native context object,
+// filled by the passed update.
+func (b *Bot) NewContext(upd Update) Context {
+	return &nativeContext{
+		b: b,
+		u: upd,
+	}
+}
+
+// nativeContext is a native implementation of the Context interface.
+type nativeContext struct {
+	b     *Bot
+	u     Update
+	lock  sync.RWMutex
+	store map[string]interface{}
+}
+
+func (c *nativeContext) Bot() *Bot {
+	return c.b
+}
+
+func (c *nativeContext) Update() Update {
+	return c.u
+}
+
+func (c *nativeContext) Message() *Message {
+	switch {
+	case c.u.Message != nil:
+		return c.u.Message
+	case c.u.Callback != nil:
+		return c.u.Callback.Message
+	case c.u.EditedMessage != nil:
+		return c.u.EditedMessage
+	case c.u.ChannelPost != nil:
+		return c.u.ChannelPost
+	case c.u.EditedChannelPost != nil:
+		return c.u.EditedChannelPost
+	default:
+		return nil
+	}
+}
+
+func (c *nativeContext) Callback() *Callback {
+	return c.u.Callback
+}
+
+func (c *nativeContext) Query() *Query {
+	return c.u.Query
+}
+
+func (c *nativeContext) ChosenInlineResult() *ChosenInlineResult {
+	return c.u.ChosenInlineResult
+}
+
+func (c *nativeContext) ShippingQuery() *ShippingQuery {
+	return c.u.ShippingQuery
+}
+
+func (c *nativeContext) PreCheckoutQuery() *PreCheckoutQuery {
+	return c.u.PreCheckoutQuery
+}
+
+func (c *nativeContext) Poll() *Poll {
+	return c.u.Poll
+}
+
+func (c *nativeContext) PollAnswer() *PollAnswer {
+	return c.u.PollAnswer
+}
+
+func (c *nativeContext) Get(key string) interface{} {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.store[key]
+}
+
+func (c *nativeContext) Set(key string, val interface{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.store == nil {
+		c.store = make(map[string]interface{})
+	}
+	c.store[key] = val
+}
+
+func (c *nativeContext) Session() Session {
+	var (
+		chat int64
+		user int
+	)
+	if c.Chat() != nil {
+		chat = c.Chat().ID
+	}
+	if c.Sender() != nil {
+		user = c.Sender().ID
+	}
+	return NewSession(c.b.storage, chat, user)
+}
+
+func (c *nativeContext) Sender() *User {
+	switch {
+	case c.u.Callback != nil:
+		return c.u.Callback.Sender
+	case c.Message() != nil:
+		return c.Message().Sender
+	case c.u.Query != nil:
+		return &c.u.Query.From
+	case c.u.ChosenInlineResult != nil:
+		return &c.u.ChosenInlineResult.From
+	case c.u.ShippingQuery != nil:
+		return c.u.ShippingQuery.Sender
+	case c.u.PreCheckoutQuery != nil:
+		return c.u.PreCheckoutQuery.Sender
+	case c.u.PollAnswer != nil:
+		return &c.u.PollAnswer.User
+	default:
+		return nil
+	}
+}
+
+func (c *nativeContext) Chat() *Chat {
+	if m := c.Message(); m != nil {
+		return m.Chat
+	}
+	return nil
+}
+
+func (c *nativeContext) Recipient() Recipient {
+	if chat := c.Chat(); chat != nil {
+		return chat
+	}
+	if sender := c.Sender(); sender != nil {
+		return sender
+	}
+	return nil
+}
+
+func (c *nativeContext) Text() string {
+	m := c.Message()
+	if m == nil {
+		return ""
+	}
+	if m.Caption != "" {
+		return m.Caption
+	}
+	return m.Text
+}
+
+func (c *nativeContext) Data() string {
+	switch {
+	case c.u.Message != nil:
+		return c.u.Message.Payload
+	case c.u.Callback != nil:
+		return c.u.Callback.Data
+	case c.u.Query != nil:
+		return c.u.Query.Text
+	case c.u.ChosenInlineResult != nil:
+		return c.u.ChosenInlineResult.Query
+	case c.u.ShippingQuery != nil:
+		return c.u.ShippingQuery.Payload
+	case c.u.PreCheckoutQuery != nil:
+		return c.u.PreCheckoutQuery.Payload
+	default:
+		return ""
+	}
+}
+
+func (c *nativeContext) Payload() string {
+	if m := c.u.Message; m != nil {
+		return m.Payload
+	}
+	return ""
+}
+
+func (c *nativeContext) Args() []string {
+	switch {
+	case c.u.Message != nil:
+		return strings.Fields(c.u.Message.Payload)
+	case c.u.Callback != nil:
+		if c.u.Callback.Data == "" {
+			return nil
+		}
+		return strings.Split(c.u.Callback.Data, "|")
+	case c.u.Query != nil:
+		return strings.Fields(c.u.Query.Text)
+	default:
+		return nil
+	}
+}
+
+func (c *nativeContext) Send(what interface{}, opts ...interface{}) error {
+	_, err := c.b.Send(c.Recipient(), what, opts...)
+	return err
+}
+
+func (c *nativeContext) SendAlbum(a Album, opts ...interface{}) error {
+	_, err := c.b.SendAlbum(c.Recipient(), a, opts...)
+	return err
+}
+
+func (c *nativeContext) Reply(what interface{}, opts ...interface{}) error {
+	msg := c.Message()
+	if msg == nil {
+		return ErrBadContext
+	}
+	_, err := c.b.Reply(msg, what, opts...)
+	return err
+}
+
+func (c *nativeContext) Forward(msg Editable, opts ...interface{}) error {
+	_, err := c.b.Forward(c.Recipient(), msg, opts...)
+	return err
+}
+
+func (c *nativeContext) ForwardTo(to Recipient, opts ...interface{}) error {
+	msg := c.Message()
+	if msg == nil {
+		return ErrBadContext
+	}
+	_, err := c.b.Forward(to, msg, opts...)
+	return err
+}
+
+func (c *nativeContext) Edit(what interface{}, opts ...interface{}) error {
+	msg := c.Message()
+	if msg == nil {
+		return ErrBadContext
+	}
+	_, err := c.b.Edit(msg, what, opts...)
+	return err
+}
+
+func (c *nativeContext) EditCaption(caption string, opts ...interface{}) error {
+	msg := c.Message()
+	if msg == nil {
+		return ErrBadContext
+	}
+	_, err := c.b.EditCaption(msg, caption, opts...)
+	return err
+}
+
+func (c *nativeContext) Delete() error {
+	msg := c.Message()
+	if msg == nil {
+		return ErrBadContext
+	}
+	return c.b.Delete(msg)
+}
+
+func (c *nativeContext) Notify(action ChatAction) error {
+	return c.b.Notify(c.Recipient(), action)
+}
+
+func (c *nativeContext) Respond(resp ...*CallbackResponse) error {
+	if c.u.Callback == nil {
+		return ErrBadContext
+	}
+	return c.b.Respond(c.u.Callback, resp...)
+}
+
+func (c *nativeContext) Answer(resp *QueryResponse) error {
+	if c.u.Query == nil {
+		return ErrBadContext
+	}
+	return c.b.Answer(c.u.Query, resp)
+}
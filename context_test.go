@@ -0,0 +1,63 @@
+package telebot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContext(t *testing.T) {
+	b, err := NewBot(Settings{Synchronous: true, offline: true})
+	require.NoError(t, err)
+
+	var (
+		user = &User{ID: 1}
+		chat = &Chat{ID: 2}
+	)
+
+	b.Handle("/start", func(c Context) error {
+		assert.Equal(t, user, c.Sender())
+		assert.Equal(t, chat, c.Chat())
+		assert.Equal(t, chat, c.Recipient())
+		assert.Equal(t, "/start a  b", c.Text())
+		assert.Equal(t, "a  b", c.Payload())
+		assert.Equal(t, "a  b", c.Data())
+		assert.Equal(t, []string{"a", "b"}, c.Args())
+		return nil
+	})
+	b.Handle("\funique", func(c Context) error {
+		assert.Equal(t, user, c.Sender())
+		assert.Nil(t, c.Chat())
+		assert.Equal(t, user, c.Recipient())
+		assert.Equal(t, "a|b", c.Data())
+		assert.Equal(t, []string{"a", "b"}, c.Args())
+		return nil
+	})
+	b.Handle(OnQuery, HandlerFunc(func(c Context) error {
+		assert.Equal(t, user, c.Sender())
+		assert.Equal(t, "a b", c.Data())
+		assert.Equal(t, []string{"a", "b"}, c.Args())
+		assert.Equal(t, ErrBadContext, c.Edit("text"))
+		assert.Equal(t, ErrBadContext, c.Respond())
+		return nil
+	}))
+
+	b.ProcessUpdate(Update{Message: &Message{Sender: user, Chat: chat, Text: "/start a  b"}})
+	b.ProcessUpdate(Update{Callback: &Callback{Sender: user, Data: "\funique|a|b"}})
+	b.ProcessUpdate(Update{Query: &Query{From: *user, Text: "a b"}})
+
+	c := b.NewContext(Update{PollAnswer: &PollAnswer{User: *user}})
+	assert.Equal(t, user, c.Sender())
+	assert.Nil(t, c.Message())
+	assert.Empty(t, c.Text())
+	assert.Nil(t, c.Args())
+
+	c = b.NewContext(Update{Poll: &Poll{}})
+	assert.Nil(t, c.Recipient())
+	assert.Equal(t, ErrBadRecipient, c.Send("text"))
+
+	c.Set("key", "value")
+	assert.Equal(t, "value", c.Get("key"))
+	assert.Nil(t, c.Get("none"))
+}
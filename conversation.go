@@ -0,0 +1,423 @@
+package telebot
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// EndConversation is the state, which finishes the conversation.
+const EndConversation = ""
+
+// StateFunc handles a message in the conversation state and
+// returns the next state. Returning the same state keeps the
+// conversation in it, returning EndConversation finishes it.
+type StateFunc func(Context) (next string, err error)
+
+// Conversation describes a multi-step dialog with the user as
+// a set of named states. While the conversation is in progress,
+// messages of the user in the chat are routed to the handler of
+// the current state, before any commands are matched.
+//
+// The next message of the user in the chat is queued until the
+// handler of the previous one returns, so the state handlers of
+// the user never run in parallel. Other users are not delayed.
+//
+// Example:
+//
+//		b.Converse(&tb.Conversation{
+//			Entry:  []string{"/signup"},
+//			Cancel: []string{"/cancel"},
+//			Enter: func(c tb.Context) (string, error) {
+//				return "name", c.Send("What's your name?")
+//			},
+//			States: map[string]tb.StateFunc{
+//				"name": func(c tb.Context) (string, error) {
+//					return tb.EndConversation, c.Send("Hello, " + c.Text())
+//				},
+//			},
+//			Timeout: 5 * time.Minute,
+//		})
+//
+type Conversation struct {
+	// Entry are the endpoints starting the conversation,
+	// like commands or texts passed to Handle.
+	Entry []string
+
+	// Enter is called on the entry endpoints.
+	// It returns the first state of the conversation.
+	// If it fails, the conversation is not started.
+	Enter StateFunc
+
+	// States maps the state names to their handlers.
+	States map[string]StateFunc
+
+	// Cancel are the endpoints finishing the
+	// conversation in any state, like "/cancel".
+	Cancel []string
+
+	// OnCancel is called when the conversation is cancelled.
+	OnCancel HandlerFunc
+
+	// Timeout finishes the conversation if the user doesn't
+	// reply in time. By default, there is no timeout.
+	Timeout time.Duration
+
+	// OnTimeout is called on the first message after the
+	// timeout. Then the message is processed as usual.
+	OnTimeout HandlerFunc
+
+	// ReEntry lets the entry endpoints restart the conversation,
+	// which is in progress. Otherwise, they are routed to the
+	// current state as any other message.
+	ReEntry bool
+}
+
+// Converse registers the conversation entry endpoints.
+func (b *Bot) Converse(conv *Conversation) {
+	b.dialogs.mu.Lock()
+	b.dialogs.convs = append(b.dialogs.convs, conv)
+	b.dialogs.mu.Unlock()
+
+	// the endpoints, which can't be matched in converse,
+	// like OnPhoto, are handled as usual
+	for _, end := range conv.Entry {
+		b.Handle(end, func(c Context) error {
+			return b.enterConversation(conv, c)
+		})
+	}
+}
+
+// EndConversation finishes the conversation with the
+// sender of the update in its chat, if there is such.
+func (b *Bot) EndConversation(c Context) {
+	if key, ok := newDialogKey(c); ok {
+		b.dialogs.delete(key)
+	}
+}
+
+// ConversationState returns the current state of the
+// conversation with the sender of the update in its chat.
+func (b *Bot) ConversationState(c Context) (string, bool) {
+	key, ok := newDialogKey(c)
+	if !ok {
+		return EndConversation, false
+	}
+
+	d, ok := b.dialogs.get(key)
+	if !ok || d.state == EndConversation || d.expired(time.Now()) {
+		return EndConversation, false
+	}
+	return d.state, true
+}
+
+func (b *Bot) enterConversation(conv *Conversation, c Context) error {
+	key, ok := newDialogKey(c)
+	if !ok {
+		return ErrBadContext
+	}
+
+	next, err := conv.Enter(c)
+	if err != nil {
+		return err
+	}
+
+	b.dialogs.set(key, nil, newDialog(conv, next))
+	return nil
+}
+
+// converse routes the message to the current conversation
+// state. It returns false if the message has to be handled
+// as usual.
+//
+// While the handler of the previous message of the user is
+// running, the message is queued, and dispatched once the
+// handler is done, so the transitions are never lost.
+// The queued messages are dispatched again with queued set.
+func (b *Bot) converse(c Context, queued bool) bool {
+	if c.Message().IsService() {
+		return false
+	}
+
+	key, ok := newDialogKey(c)
+	if !ok {
+		return false
+	}
+
+	if !queued && b.dialogs.enqueue(key, queuedUpdate{bot: b, upd: c.Update()}) {
+		// it's in flight until dispatched
+		b.handlerStarted(c.Update().ID)
+		return true
+	}
+
+	handled, busy := b.routeDialog(key, c)
+	if queued && !busy {
+		b.dequeue(key)
+	}
+	return handled
+}
+
+// routeDialog routes the message by the dialog. It also reports
+// whether a state handler has been started, and the dialog is
+// busy until it's done.
+func (b *Bot) routeDialog(key dialogKey, c Context) (handled, busy bool) {
+	d, ok := b.dialogs.get(key)
+	if !ok {
+		return b.enter(key, c)
+	}
+	conv := d.conv
+
+	switch {
+	case d.expired(time.Now()):
+		b.dialogs.delete(key)
+		if conv.OnTimeout != nil {
+			b.runHandler(conv.OnTimeout, c)
+		}
+		return false, false
+	case b.matchEndpoint(c, conv.Cancel):
+		b.dialogs.delete(key)
+		if conv.OnCancel != nil {
+			b.runHandler(conv.OnCancel, c)
+		}
+		return true, false
+	case conv.ReEntry && b.matchEndpoint(c, conv.Entry):
+		return b.enter(key, c)
+	}
+
+	state, ok := conv.States[d.state]
+	if !ok {
+		b.dialogs.delete(key)
+		return false, false
+	}
+
+	b.transit(key, d, conv, state, c)
+	return true, true
+}
+
+// dequeue dispatches the next message waiting for the dialog,
+// or marks the dialog as free if there is no such.
+func (b *Bot) dequeue(key dialogKey) {
+	q, ok := b.dialogs.release(key)
+	if !ok {
+		return
+	}
+
+	go func() {
+		defer q.bot.handlerDone(q.upd.ID)
+		q.bot.dispatch(q.upd, true)
+	}()
+}
+
+// enter starts the conversation, which the message is an entry of.
+func (b *Bot) enter(key dialogKey, c Context) (handled, busy bool) {
+	b.dialogs.mu.Lock()
+	convs := b.dialogs.convs
+	b.dialogs.mu.Unlock()
+
+	for _, conv := range convs {
+		if b.matchEndpoint(c, conv.Entry) {
+			b.transit(key, nil, conv, conv.Enter, c)
+			return true, true
+		}
+	}
+	return false, false
+}
+
+// transit runs the state handler and stores the next state. Until
+// the handler is done, the dialog is busy, so converse queues the
+// next messages instead of routing them by the stale state.
+//
+// If the handler is not run, e.g. stopped by a middleware, the old
+// dialog is restored. A nil old dialog means the conversation is
+// being entered, and it's not started if Enter fails.
+func (b *Bot) transit(key dialogKey, d *dialog, conv *Conversation, state StateFunc, c Context) {
+	b.dialogs.acquire(key)
+
+	pending := &dialog{conv: conv}
+	if d != nil {
+		pending.state = d.state
+	}
+	b.dialogs.set(key, d, pending)
+
+	next := d
+	b.runHandlerThen(func(c Context) error {
+		state, err := state(c)
+		if d == nil && err != nil {
+			return err
+		}
+		next = newDialog(conv, state)
+		return err
+	}, c, func() {
+		b.dialogs.set(key, pending, next)
+		b.dequeue(key)
+	})
+}
+
+// matchEndpoint reports whether the message text
+// or its command is one of the endpoints.
+func (b *Bot) matchEndpoint(c Context, ends []string) bool {
+	text := c.Message().Text
+
+	var command string
+	if match := cmdRx.FindStringSubmatch(text); match != nil {
+		command = match[1]
+		if bot := match[3]; bot != "" && !strings.EqualFold(b.Me.Username, bot) {
+			command = ""
+		}
+	}
+
+	for _, end := range ends {
+		if end == text || end == command {
+			return true
+		}
+	}
+	return false
+}
+
+type dialogKey struct {
+	chat int64
+	user int
+}
+
+func newDialogKey(c Context) (dialogKey, bool) {
+	chat, sender := c.Chat(), c.Sender()
+	if chat == nil || sender == nil {
+		return dialogKey{}, false
+	}
+	return dialogKey{chat: chat.ID, user: sender.ID}, true
+}
+
+// dialog is the state of the conversation with the user.
+// It's immutable, every transition creates a new one.
+type dialog struct {
+	conv    *Conversation
+	state   string
+	expires time.Time
+}
+
+// newDialog returns nil if the conversation is finished.
+func newDialog(conv *Conversation, state string) *dialog {
+	if state == EndConversation {
+		return nil
+	}
+
+	d := &dialog{conv: conv, state: state}
+	if conv.Timeout > 0 {
+		d.expires = time.Now().Add(conv.Timeout)
+	}
+	return d
+}
+
+func (d *dialog) expired(now time.Time) bool {
+	return !d.expires.IsZero() && now.After(d.expires)
+}
+
+type dialogs struct {
+	mu    sync.Mutex
+	data  map[dialogKey]*dialog
+	convs []*Conversation
+
+	// the messages waiting for the busy dialogs,
+	// a dialog is busy if it's in the map
+	busy map[dialogKey][]queuedUpdate
+}
+
+// queuedUpdate is the message waiting for the handler
+// of the previous one, along with the bot view it has
+// been received with.
+type queuedUpdate struct {
+	bot *Bot
+	upd Update
+}
+
+// enqueue queues the message if the dialog is busy.
+func (ds *dialogs) enqueue(key dialogKey, q queuedUpdate) bool {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	queue, ok := ds.busy[key]
+	if ok {
+		ds.busy[key] = append(queue, q)
+	}
+	return ok
+}
+
+// acquire marks the dialog as busy.
+func (ds *dialogs) acquire(key dialogKey) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if ds.busy == nil {
+		ds.busy = make(map[dialogKey][]queuedUpdate)
+	}
+	if _, ok := ds.busy[key]; !ok {
+		ds.busy[key] = nil
+	}
+}
+
+// release takes the next queued message of the dialog,
+// or marks the dialog as free if there is no such.
+func (ds *dialogs) release(key dialogKey) (queuedUpdate, bool) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	queue := ds.busy[key]
+	if len(queue) == 0 {
+		delete(ds.busy, key)
+		return queuedUpdate{}, false
+	}
+
+	ds.busy[key] = queue[1:]
+	return queue[0], true
+}
+
+func (ds *dialogs) get(key dialogKey) (*dialog, bool) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	d, ok := ds.data[key]
+	return d, ok
+}
+
+// set replaces the dialog if it's still the old one, so
+// the transition is lost when the conversation has been
+// cancelled or restarted meanwhile. A nil old dialog is
+// always replaced, a nil new one is deleted.
+func (ds *dialogs) set(key dialogKey, old, d *dialog) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if old != nil && ds.data[key] != old {
+		return
+	}
+	if d == nil {
+		delete(ds.data, key)
+		return
+	}
+
+	if ds.data == nil {
+		ds.data = make(map[dialogKey]*dialog)
+	}
+	if _, ok := ds.data[key]; !ok {
+		ds.sweep(time.Now())
+	}
+	ds.data[key] = d
+}
+
+func (ds *dialogs) delete(key dialogKey) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	delete(ds.data, key)
+}
+
+// sweep removes the expired dialogs, so the map stays bounded.
+func (ds *dialogs) sweep(now time.Time) {
+	if len(ds.data) < 1024 {
+		return
+	}
+
+	for key, d := range ds.data {
+		if d.expired(now) {
+			delete(ds.data, key)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package telebot
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConversation(t *testing.T) {
+	b, err := NewBot(Settings{Synchronous: true, offline: true})
+	require.NoError(t, err)
+
+	var got []string
+	record := func(s string) { got = append(got, s) }
+
+	b.Handle("/start", func(c Context) error {
+		record("start")
+		return nil
+	})
+	b.Handle(OnText, func(c Context) error {
+		record("text " + c.Text())
+		return nil
+	})
+
+	conv := &Conversation{
+		Entry:  []string{"/signup"},
+		Cancel: []string{"/cancel"},
+		Enter: func(c Context) (string, error) {
+			record("enter")
+			return "name", nil
+		},
+		States: map[string]StateFunc{
+			"name": func(c Context) (string, error) {
+				record("name " + c.Text())
+				if c.Text() == "" {
+					return "name", nil
+				}
+				return "age", nil
+			},
+			"age": func(c Context) (string, error) {
+				record("age " + c.Text())
+				return EndConversation, nil
+			},
+		},
+		OnCancel: func(c Context) error {
+			record("cancel")
+			return nil
+		},
+	}
+	b.Converse(conv)
+
+	send := func(chat int64, user int, text string) {
+		b.ProcessUpdate(Update{Message: &Message{
+			Text:   text,
+			Chat:   &Chat{ID: int64(chat)},
+			Sender: &User{ID: user},
+		}})
+	}
+
+	t.Run("states", func(t *testing.T) {
+		got = nil
+
+		send(1, 1, "/signup")
+		state, ok := b.ConversationState(b.NewContext(Update{Message: &Message{Chat: &Chat{ID: 1}, Sender: &User{ID: 1}}}))
+		assert.True(t, ok)
+		assert.Equal(t, "name", state)
+
+		// other users and chats are not affected
+		send(1, 2, "hi")
+		send(2, 1, "hi")
+
+		// malicious messages and commands to other bots are dropped
+		send(1, 1, "\abad")
+		send(1, 1, "/start@other_bot")
+
+		// commands are routed to the state as well
+		send(1, 1, "/start")
+		send(1, 1, "25")
+		send(1, 1, "after")
+
+		assert.Equal(t, []string{
+			"enter", "text hi", "text hi",
+			"name /start", "age 25", "text after",
+		}, got)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		got = nil
+
+		send(1, 1, "/signup")
+		send(1, 1, "/cancel")
+		send(1, 1, "text")
+
+		assert.Equal(t, []string{"enter", "cancel", "text text"}, got)
+	})
+
+	t.Run("reentry", func(t *testing.T) {
+		got = nil
+
+		send(1, 1, "/signup")
+		send(1, 1, "/signup")
+		conv.ReEntry = true
+		send(1, 1, "/signup")
+		send(1, 1, "name")
+		conv.ReEntry = false
+		b.EndConversation(b.NewContext(Update{Message: &Message{Chat: &Chat{ID: 1}, Sender: &User{ID: 1}}}))
+		send(1, 1, "text")
+
+		assert.Equal(t, []string{
+			"enter", "name /signup", "enter", "name name", "text text",
+		}, got)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		got = nil
+
+		conv.Timeout = 10 * time.Millisecond
+		conv.OnTimeout = func(c Context) error {
+			record("timeout")
+			return nil
+		}
+		defer func() { conv.Timeout, conv.OnTimeout = 0, nil }()
+
+		send(1, 1, "/signup")
+		send(1, 1, "name")
+		time.Sleep(20 * time.Millisecond)
+		send(1, 1, "25")
+
+		assert.Equal(t, []string{"enter", "name name", "timeout", "text 25"}, got)
+	})
+}
+
+func TestConversationWorkers(t *testing.T) {
+	b, err := NewBot(Settings{Workers: 2, Ordering: OrderByChat, offline: true})
+	require.NoError(t, err)
+
+	var (
+		mu  sync.Mutex
+		got []string
+	)
+	record := func(s string) {
+		mu.Lock()
+		got = append(got, s)
+		mu.Unlock()
+	}
+
+	b.Handle(OnText, func(c Context) error {
+		record("ontext")
+		return nil
+	})
+	b.Converse(&Conversation{
+		Entry: []string{"/signup", "/fail"},
+		Enter: func(c Context) (string, error) {
+			if c.Text() == "/fail" {
+				return "name", errors.New("failed")
+			}
+			time.Sleep(20 * time.Millisecond)
+			record("enter")
+			return "name", nil
+		},
+		States: map[string]StateFunc{
+			"name": func(c Context) (string, error) {
+				time.Sleep(10 * time.Millisecond)
+				record(c.Text())
+				return "age", nil
+			},
+			"age": func(c Context) (string, error) {
+				record("age " + c.Text())
+				return EndConversation, nil
+			},
+		},
+	})
+
+	send := func(text string) {
+		b.ProcessUpdate(Update{Message: &Message{
+			Text:   text,
+			Chat:   &Chat{ID: 1},
+			Sender: &User{ID: 1},
+		}})
+	}
+
+	send("/signup")
+	send("bob")
+	send("25")
+	send("/fail")
+	send("text")
+
+	_, err = b.Shutdown(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"enter", "bob", "age 25", "ontext"}, got)
+}
+
+func TestConversationQueue(t *testing.T) {
+	b, err := NewBot(Settings{offline: true})
+	require.NoError(t, err)
+
+	var (
+		mu  sync.Mutex
+		got []string
+	)
+	record := func(s string) {
+		mu.Lock()
+		got = append(got, s)
+		mu.Unlock()
+	}
+
+	other := make(chan struct{})
+	b.Handle(OnText, func(c Context) error {
+		close(other)
+		return nil
+	})
+	b.Converse(&Conversation{
+		Entry: []string{"/signup"},
+		Enter: func(c Context) (string, error) {
+			time.Sleep(300 * time.Millisecond)
+			record("enter")
+			return "name", nil
+		},
+		States: map[string]StateFunc{
+			"name": func(c Context) (string, error) {
+				record(c.Text())
+				return "age", nil
+			},
+			"age": func(c Context) (string, error) {
+				record("age " + c.Text())
+				return EndConversation, nil
+			},
+		},
+	})
+
+	send := func(chat int, text string) {
+		b.ProcessUpdate(Update{Message: &Message{
+			Text:   text,
+			Chat:   &Chat{ID: int64(chat)},
+			Sender: &User{ID: chat},
+		}})
+	}
+
+	start := time.Now()
+	send(1, "/signup")
+	send(1, "bob")
+	send(1, "25")
+	send(2, "text")
+
+	select {
+	case <-other:
+		assert.True(t, time.Since(start) < 100*time.Millisecond)
+	case <-time.After(time.Second):
+		t.Fatal("the other chat is not handled")
+	}
+
+	_, err = b.Shutdown(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"enter", "bob", "age 25"}, got)
+}
@@ -0,0 +1,136 @@
+package telebot
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Deduplicator filters out the updates delivered more than once,
+// e.g. when Telegram redelivers a webhook update, since the
+// previous request has timed out. Implementations must be safe
+// for concurrent use.
+type Deduplicator interface {
+	// Seen marks the update as seen and reports
+	// whether it has already been seen before.
+	Seen(id int) (bool, error)
+
+	// Forget unmarks the update, which hasn't been
+	// processed, so it's accepted when delivered again.
+	Forget(id int) error
+}
+
+// NewDeduplicator creates an in-memory Deduplicator, which
+// remembers the IDs of the last size updates. Default: 1000
+func NewDeduplicator(size int) Deduplicator {
+	if size <= 0 {
+		size = 1000
+	}
+	return &windowDedup{
+		ring: make([]windowEntry, size),
+		seen: make(map[int]uint64, size),
+	}
+}
+
+type windowEntry struct {
+	id  int
+	seq uint64
+}
+
+// windowDedup is a bounded window of update IDs. The
+// sequence numbers let the ring tell forgotten and
+// re-added IDs from the ones it holds.
+type windowDedup struct {
+	mu   sync.Mutex
+	ring []windowEntry
+	seen map[int]uint64
+	seq  uint64
+}
+
+func (d *windowDedup) Seen(id int) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.seen[id]; ok {
+		return true, nil
+	}
+
+	d.seq++
+	i := d.seq % uint64(len(d.ring))
+
+	if old := d.ring[i]; old.seq != 0 && d.seen[old.id] == old.seq {
+		delete(d.seen, old.id)
+	}
+
+	d.ring[i] = windowEntry{id: id, seq: d.seq}
+	d.seen[id] = d.seq
+	return false, nil
+}
+
+func (d *windowDedup) Forget(id int) error {
+	d.mu.Lock()
+	delete(d.seen, id)
+	d.mu.Unlock()
+	return nil
+}
+
+// NewStorageDeduplicator creates a Deduplicator keeping the
+// update IDs in the storage for the ttl, so several bot
+// instances could share it. Default ttl: 1 hour
+//
+// The storage is checked and updated with two separate calls,
+// so the same update delivered to several instances at once
+// might be accepted by more than one of them.
+func NewStorageDeduplicator(storage Storage, ttl time.Duration) Deduplicator {
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return &storageDedup{storage: storage, ttl: ttl}
+}
+
+type storageDedup struct {
+	storage Storage
+	ttl     time.Duration
+}
+
+func (d *storageDedup) key(id int) string {
+	return "telebot:update:" + strconv.Itoa(id)
+}
+
+func (d *storageDedup) Seen(id int) (bool, error) {
+	_, ok, err := d.storage.Get(d.key(id))
+	if err != nil || ok {
+		return ok, err
+	}
+	return false, d.storage.Set(d.key(id), []byte("1"), d.ttl)
+}
+
+func (d *storageDedup) Forget(id int) error {
+	return d.storage.Delete(d.key(id))
+}
+
+// duplicate reports whether the update has to be skipped.
+// Deduplicator errors are reported, and the update is let
+// through, so it's not lost.
+func (b *Bot) duplicate(d Deduplicator, upd Update) bool {
+	if d == nil {
+		return false
+	}
+
+	seen, err := d.Seen(upd.ID)
+	if err != nil {
+		b.debug(err)
+		return false
+	}
+	return seen
+}
+
+// forget unmarks the update, which hasn't been handed off.
+func (b *Bot) forget(d Deduplicator, upd Update) {
+	if d == nil {
+		return
+	}
+	if err := d.Forget(upd.ID); err != nil {
+		b.debug(err)
+	}
+}
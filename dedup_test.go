@@ -0,0 +1,88 @@
+package telebot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeduplicator(t *testing.T) {
+	d := NewDeduplicator(2)
+
+	seen := func(id int) bool {
+		ok, err := d.Seen(id)
+		require.NoError(t, err)
+		return ok
+	}
+
+	assert.False(t, seen(1))
+	assert.True(t, seen(1))
+	assert.False(t, seen(2))
+	assert.False(t, seen(3))
+
+	// the window is bounded
+	assert.False(t, seen(1))
+
+	// forgotten and re-added updates are not evicted by
+	// the older entries
+	require.NoError(t, d.Forget(3))
+	assert.False(t, seen(4))
+	assert.False(t, seen(3))
+	assert.True(t, seen(3))
+
+	s := NewStorageDeduplicator(NewMemoryStorage(0), 10*time.Millisecond)
+	ok, err := s.Seen(1)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, _ = s.Seen(1)
+	assert.True(t, ok)
+
+	require.NoError(t, s.Forget(1))
+	ok, _ = s.Seen(1)
+	assert.False(t, ok)
+
+	time.Sleep(20 * time.Millisecond)
+	ok, _ = s.Seen(1)
+	assert.False(t, ok)
+}
+
+func TestWebhookDedup(t *testing.T) {
+	b, err := NewBot(Settings{offline: true})
+	require.NoError(t, err)
+
+	dest := make(chan Update, 2)
+	stop := make(chan struct{})
+
+	h := &Webhook{
+		Dedup: NewDeduplicator(0),
+		dest:  dest,
+		stop:  stop,
+		bot:   b,
+	}
+
+	serve := func(body string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(`{"update_id":1}`))
+	assert.Equal(t, http.StatusOK, serve(`{"update_id":1}`))
+	assert.Len(t, dest, 1)
+
+	// the update rejected on stop is accepted later
+	close(stop)
+	h.dest = make(chan Update)
+	assert.Equal(t, http.StatusServiceUnavailable, serve(`{"update_id":2}`))
+
+	h.dest, h.stop = dest, make(chan struct{})
+	assert.Equal(t, http.StatusOK, serve(`{"update_id":2}`))
+	assert.Len(t, dest, 2)
+}
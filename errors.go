@@ -2,8 +2,10 @@ package telebot
 
 import (
 	"fmt"
-	"regexp"
+	"net/http"
 	"strings"
+
+	"github.com/pkg/errors"
 )
 
 type APIError struct {
@@ -17,6 +19,24 @@ type FloodError struct {
 	RetryAfter int
 }
 
+// GroupMigratedError is returned when the group has been
+// upgraded to a supergroup, so the requests must be sent
+// to the new chat. It matches ErrGroupMigrated with errors.Is.
+type GroupMigratedError struct {
+	*APIError
+	MigratedTo int64
+}
+
+// Unwrap returns the underlying APIError.
+func (err GroupMigratedError) Unwrap() error {
+	return err.APIError
+}
+
+// Unwrap returns the underlying APIError.
+func (err FloodError) Unwrap() error {
+	return err.APIError
+}
+
 // ʔ returns description of error.
 // A tiny shortcut to make code clearer.
 func (err *APIError) ʔ() string {
@@ -37,6 +57,23 @@ func (err *APIError) Error() string {
 	return fmt.Sprintf("telegram: %s (%d)", msg, err.Code)
 }
 
+// Is reports whether the error matches the target APIError.
+// Errors are matched by the code and the description prefix,
+// so more detailed errors match the general ones:
+//
+//		errors.Is(ErrSameMessageContent, ErrMessageNotModified) // true
+//
+func (err *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	if !ok || t == nil {
+		return false
+	}
+	return err.Code == t.Code && strings.HasPrefix(
+		strings.ToLower(err.Description),
+		strings.ToLower(t.Description),
+	)
+}
+
 // NewAPIError returns new APIError instance with given description.
 // First element of msgs is Description. The second is optional Message.
 func NewAPIError(code int, msgs ...string) *APIError {
@@ -50,16 +87,15 @@ func NewAPIError(code int, msgs ...string) *APIError {
 	return err
 }
 
-var errorRx = regexp.MustCompile(`{.+"error_code":(\d+),"description":"(.+)"(?:,"parameters":{"retry_after":(\d+)})?}`)
-
 var (
 	// General errors
 	ErrUnauthorized      = NewAPIError(401, "Unauthorized")
 	ErrNotStartedByUser  = NewAPIError(403, "Forbidden: bot can't initiate conversation with a user")
-	ErrBlockedByUser     = NewAPIError(401, "Forbidden: bot was blocked by the user")
-	ErrUserIsDeactivated = NewAPIError(401, "Forbidden: user is deactivated")
+	ErrBlockedByUser     = NewAPIError(403, "Forbidden: bot was blocked by the user")
+	ErrUserIsDeactivated = NewAPIError(403, "Forbidden: user is deactivated")
 	ErrNotFound          = NewAPIError(404, "Not Found")
 	ErrInternal          = NewAPIError(500, "Internal Server Error")
+	ErrWebhookActive     = NewAPIError(409, "Conflict: can't use getUpdates method while webhook is active")
 
 	// Bad request errors
 	ErrTooLarge             = NewAPIError(400, "Request Entity Too Large")
@@ -98,6 +134,7 @@ var (
 	// Super/groups errors
 	ErrBotKickedFromGroup      = NewAPIError(403, "Forbidden: bot was kicked from the group chat")
 	ErrBotKickedFromSuperGroup = NewAPIError(403, "Forbidden: bot was kicked from the supergroup chat")
+	ErrGroupMigrated           = NewAPIError(400, "Bad Request: group chat was upgraded to a supergroup chat")
 )
 
 // ErrByDescription returns APIError instance by given description.
@@ -109,6 +146,8 @@ func ErrByDescription(s string) error {
 		return ErrNotStartedByUser
 	case ErrNotFound.ʔ():
 		return ErrNotFound
+	case ErrInternal.ʔ():
+		return ErrInternal
 	case ErrUserIsDeactivated.ʔ():
 		return ErrUserIsDeactivated
 	case ErrToForwardNotFound.ʔ():
@@ -154,9 +193,11 @@ func ErrByDescription(s string) error {
 	case ErrKickingChatOwner.ʔ():
 		return ErrKickingChatOwner
 	case ErrBotKickedFromGroup.ʔ():
-		return ErrKickingChatOwner
+		return ErrBotKickedFromGroup
 	case ErrBotKickedFromSuperGroup.ʔ():
 		return ErrBotKickedFromSuperGroup
+	case ErrGroupMigrated.ʔ():
+		return ErrGroupMigrated
 	case ErrWrongTypeOfContent.ʔ():
 		return ErrWrongTypeOfContent
 	case ErrBadURLContent.ʔ():
@@ -181,3 +222,33 @@ func ErrByDescription(s string) error {
 		return nil
 	}
 }
+
+// IsForbidden reports whether the bot is not allowed
+// to perform the request, e.g. it was blocked by the user.
+func IsForbidden(err error) bool {
+	return errorCode(err) == http.StatusForbidden
+}
+
+// IsBadRequest reports whether the request is rejected
+// because of its parameters.
+func IsBadRequest(err error) bool {
+	return errorCode(err) == http.StatusBadRequest
+}
+
+// IsRetryable reports whether the same request could
+// succeed later: the error is a FloodError, a Telegram
+// server error or a network failure.
+func IsRetryable(err error) bool {
+	var flood FloodError
+	return errors.As(err, &flood) || isTemporary(err)
+}
+
+// errorCode returns the code of the APIError in the
+// chain, or zero if there is no such.
+func errorCode(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return 0
+}
@@ -0,0 +1,51 @@
+package telebot
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIErrorIs(t *testing.T) {
+	assert.True(t, errors.Is(ErrSameMessageContent, ErrMessageNotModified))
+	assert.False(t, errors.Is(ErrMessageNotModified, ErrSameMessageContent))
+	assert.False(t, errors.Is(ErrChatNotFound, ErrNotFound))
+
+	err := NewAPIError(403, "Forbidden: bot was blocked by the user (since 2020)")
+	assert.True(t, errors.Is(err, ErrBlockedByUser))
+	assert.False(t, errors.Is(NewAPIError(400, ErrBlockedByUser.Description), ErrBlockedByUser))
+
+	var flood error = FloodError{APIError: NewAPIError(429, "Too Many Requests: retry after 5")}
+	assert.True(t, errors.Is(flood, NewAPIError(429, "Too Many Requests")))
+}
+
+func TestErrByDescription(t *testing.T) {
+	assert.Equal(t, ErrBotKickedFromGroup, ErrByDescription(ErrBotKickedFromGroup.Description))
+	assert.Equal(t, ErrKickingChatOwner, ErrByDescription(ErrKickingChatOwner.Description))
+	assert.Nil(t, ErrByDescription("unknown error"))
+}
+
+func TestErrorCategories(t *testing.T) {
+	unknown := extractOk([]byte(`{"ok":false,"error_code":403,"description":"Forbidden: something new"}`))
+
+	var apiErr *APIError
+	assert.True(t, errors.As(unknown, &apiErr))
+	assert.Equal(t, 403, apiErr.Code)
+
+	assert.True(t, IsForbidden(unknown))
+	assert.True(t, IsForbidden(ErrBotKickedFromGroup))
+	assert.False(t, IsForbidden(ErrChatNotFound))
+	assert.False(t, IsForbidden(nil))
+
+	assert.True(t, IsBadRequest(ErrChatNotFound))
+	assert.False(t, IsBadRequest(ErrBlockedByUser))
+
+	assert.True(t, IsRetryable(FloodError{APIError: NewAPIError(429, "Too Many Requests"), RetryAfter: 1}))
+	assert.True(t, IsRetryable(ErrInternal))
+	assert.True(t, IsRetryable(NewAPIError(502, "Bad Gateway")))
+	assert.True(t, IsRetryable(&net.OpError{Op: "dial", Err: errors.New("refused")}))
+	assert.False(t, IsRetryable(ErrChatNotFound))
+	assert.False(t, IsRetryable(errors.New("telebot: some error")))
+}
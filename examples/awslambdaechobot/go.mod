@@ -4,5 +4,7 @@ go 1.14
 
 require (
 	github.com/aws/aws-lambda-go v1.17.0
-	gopkg.in/tucnak/telebot.v2 v2.3.1
+	github.com/demget/telebot v0.0.0
 )
+
+replace github.com/demget/telebot => ../..
@@ -6,7 +6,7 @@ import (
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
-	tb "gopkg.in/tucnak/telebot.v2"
+	tb "github.com/demget/telebot"
 )
 
 func main() {
@@ -18,13 +18,26 @@ func main() {
 		panic(err)
 	}
 
-	b.Handle(tb.OnText, func(m *tb.Message) { b.Send(m.Chat, m.Text) })
+	b.Handle(tb.OnText, func(c tb.Context) error {
+		// sent in the response, saving a request to Telegram
+		return tb.WebhookReply(c).Send(c.Text())
+	})
+
+	lambda.Start(func(req events.APIGatewayProxyRequest) (resp events.APIGatewayProxyResponse, err error) {
+		resp.StatusCode = 200
 
-	lambda.Start(func(req events.APIGatewayProxyRequest) (err error) {
 		var u tb.Update
-		if err = json.Unmarshal([]byte(req.Body), &u); err == nil {
-			b.ProcessUpdate(u)
+		if err = json.Unmarshal([]byte(req.Body), &u); err != nil {
+			return
 		}
+
+		body, err := b.ProcessUpdateReply(u)
+		if err != nil || body == nil {
+			return
+		}
+
+		resp.Headers = map[string]string{"Content-Type": "application/json"}
+		resp.Body = string(body)
 		return
 	})
 }
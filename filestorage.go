@@ -0,0 +1,339 @@
+package telebot
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// FileStorage is a durable Storage, which keeps the values in
+// memory and appends every change to a JSON log file, so they
+// survive restarts. The log is compacted once it has grown
+// twice as large as the number of the values.
+//
+// Every change is written to the file before returning, but
+// it's not synced to the disk.
+type FileStorage struct {
+	path string
+	size int
+
+	mu      sync.Mutex
+	file    *os.File
+	order   *list.List
+	items   map[string]*list.Element
+	records int
+	sweepAt int
+}
+
+type fileItem struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+func (it *fileItem) expired(now time.Time) bool {
+	return !it.expires.IsZero() && now.After(it.expires)
+}
+
+// fileRecord is a line of the log.
+type fileRecord struct {
+	Key     string `json:"key"`
+	Value   []byte `json:"value,omitempty"`
+	Expires int64  `json:"expires,omitempty"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+// minCompaction is the number of records the
+// log is never compacted below.
+const minCompaction = 1024
+
+// NewFileStorage opens the log at the path, creating it if it
+// doesn't exist. All the values are kept in memory, so like with
+// MemoryStorage, the least recently used ones are evicted, and
+// deleted from the log, when there are more than size of them.
+// Zero size means there is no limit. The expired values are swept
+// out of memory once their number has doubled since the last sweep.
+func NewFileStorage(path string, size int) (*FileStorage, error) {
+	s := &FileStorage{
+		path:    path,
+		size:    size,
+		order:   list.New(),
+		items:   make(map[string]*list.Element),
+		sweepAt: minCompaction,
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	s.file = file
+
+	return s, nil
+}
+
+// load replays the log. A broken last line, which may be
+// left after a crash, is cut off.
+func (s *FileStorage) load() error {
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return wrapError(err)
+	}
+	defer file.Close()
+
+	var (
+		now    = time.Now()
+		reader = bufio.NewReader(file)
+		offset int64
+	)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				return wrapError(os.Truncate(s.path, offset))
+			}
+			return nil
+		}
+		if err != nil {
+			return wrapError(err)
+		}
+
+		var rec fileRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return errors.Wrapf(err, "telebot: broken storage record %d", s.records+1)
+		}
+
+		offset += int64(len(line))
+		s.records++
+
+		// the evicted values are deleted by the next records
+		s.apply(rec, now)
+		s.evict()
+	}
+}
+
+func (s *FileStorage) apply(rec fileRecord, now time.Time) {
+	it := &fileItem{key: rec.Key, value: rec.Value}
+	if rec.Expires > 0 {
+		it.expires = time.Unix(0, rec.Expires)
+	}
+
+	el, ok := s.items[rec.Key]
+	switch {
+	case rec.Deleted || it.expired(now):
+		if ok {
+			s.remove(el)
+		}
+	case ok:
+		el.Value = it
+		s.order.MoveToFront(el)
+	default:
+		s.items[rec.Key] = s.order.PushFront(it)
+	}
+}
+
+// evict removes the least recently used value from
+// memory if the size limit is exceeded, and returns it.
+func (s *FileStorage) evict() *fileItem {
+	if s.size <= 0 || s.order.Len() <= s.size {
+		return nil
+	}
+
+	el := s.order.Back()
+	s.remove(el)
+	return el.Value.(*fileItem)
+}
+
+// sweep removes the expired values from memory.
+// They are dropped from the log on compaction.
+func (s *FileStorage) sweep(now time.Time) {
+	if s.order.Len() < s.sweepAt {
+		return
+	}
+
+	for _, el := range s.items {
+		if el.Value.(*fileItem).expired(now) {
+			s.remove(el)
+		}
+	}
+
+	s.sweepAt = 2 * s.order.Len()
+	if s.sweepAt < minCompaction {
+		s.sweepAt = minCompaction
+	}
+}
+
+func (s *FileStorage) remove(el *list.Element) {
+	s.order.Remove(el)
+	delete(s.items, el.Value.(*fileItem).key)
+}
+
+// Get implements Storage interface.
+func (s *FileStorage) Get(key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	it := el.Value.(*fileItem)
+	if it.expired(time.Now()) {
+		s.remove(el)
+		return nil, false, nil
+	}
+
+	s.order.MoveToFront(el)
+	return it.value, true, nil
+}
+
+// Len returns the number of values kept in memory, including
+// the expired ones, which haven't been swept out yet.
+func (s *FileStorage) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+// Set implements Storage interface.
+func (s *FileStorage) Set(key string, value []byte, ttl time.Duration) error {
+	rec := fileRecord{Key: key, Value: value}
+	if ttl > 0 {
+		rec.Expires = time.Now().Add(ttl).UnixNano()
+	}
+	return s.write(rec)
+}
+
+// Delete implements Storage interface.
+func (s *FileStorage) Delete(key string) error {
+	s.mu.Lock()
+	_, ok := s.items[key]
+	s.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+	return s.write(fileRecord{Key: key, Deleted: true})
+}
+
+// Close closes the log file.
+func (s *FileStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+func (s *FileStorage) write(rec fileRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return wrapError(err)
+	}
+
+	s.records++
+
+	now := time.Now()
+	s.apply(rec, now)
+	s.sweep(now)
+
+	if it := s.evict(); it != nil {
+		data, err := json.Marshal(fileRecord{Key: it.key, Deleted: true})
+		if err != nil {
+			return wrapError(err)
+		}
+		if _, err := s.file.Write(append(data, '\n')); err != nil {
+			return wrapError(err)
+		}
+		s.records++
+	}
+
+	if s.records > minCompaction && s.records > 2*len(s.items) {
+		return s.compact()
+	}
+	return nil
+}
+
+// compact rewrites the log with the actual values only.
+func (s *FileStorage) compact() error {
+	tmp := s.path + ".tmp"
+
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	now := time.Now()
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+
+	// the least recently used values go first,
+	// so the order is restored on load
+	var (
+		records int
+		prev    *list.Element
+	)
+	for el := s.order.Back(); el != nil; el = prev {
+		prev = el.Prev()
+
+		it := el.Value.(*fileItem)
+		if it.expired(now) {
+			s.remove(el)
+			continue
+		}
+
+		rec := fileRecord{Key: it.key, Value: it.value}
+		if !it.expires.IsZero() {
+			rec.Expires = it.expires.UnixNano()
+		}
+		if err := enc.Encode(rec); err != nil {
+			file.Close()
+			return wrapError(err)
+		}
+		records++
+	}
+
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return wrapError(err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return wrapError(err)
+	}
+	if err := file.Close(); err != nil {
+		return wrapError(err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return wrapError(err)
+	}
+
+	file, err = os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	s.file.Close()
+	s.file = file
+	s.records = records
+	return nil
+}
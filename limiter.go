@@ -0,0 +1,234 @@
+package telebot
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limiter throttles outgoing Bot API requests. It's called right
+// before every request, including retried ones. Implement it to
+// share the limits between several processes.
+type Limiter interface {
+	// Wait blocks until the request is allowed to be sent or
+	// the context is done. The chat is the chat_id parameter
+	// of the request, empty if there is no such.
+	Wait(ctx context.Context, method, chat string) error
+}
+
+// Rate is a number of requests allowed per period of time.
+// Requests can burst up to Count at once.
+type Rate struct {
+	Count int
+	Per   time.Duration
+}
+
+func (r Rate) valid() bool {
+	return r.Count > 0 && r.Per > 0
+}
+
+// RateLimits configures the default Limiter implementation.
+// See https://core.telegram.org/bots/faq#my-bot-is-hitting-limits-how-do-i-avoid-this
+type RateLimits struct {
+	// Global limits all the messages sent by the bot.
+	// Default: 30 per second
+	Global Rate
+
+	// Private limits messages sent to a single private chat.
+	// Default: 1 per second
+	Private Rate
+
+	// Group limits messages sent to a single group or channel.
+	// Default: 20 per minute
+	Group Rate
+}
+
+// RateLimiter is the default in-process Limiter, which
+// limits only the methods sending messages to chats.
+type RateLimiter struct {
+	limits RateLimits
+
+	mu     sync.Mutex
+	global bucket
+	chats  map[string]*bucket
+}
+
+// NewRateLimiter creates a Limiter matching the limits of
+// Telegram. Zero Rate fields are set to the defaults.
+//
+// Example:
+//
+//		b, err := tb.NewBot(tb.Settings{
+//			Token:   token,
+//			Limiter: tb.NewRateLimiter(tb.RateLimits{}),
+//		})
+//
+func NewRateLimiter(limits RateLimits) *RateLimiter {
+	if !limits.Global.valid() {
+		limits.Global = Rate{Count: 30, Per: time.Second}
+	}
+	if !limits.Private.valid() {
+		limits.Private = Rate{Count: 1, Per: time.Second}
+	}
+	if !limits.Group.valid() {
+		limits.Group = Rate{Count: 20, Per: time.Minute}
+	}
+
+	return &RateLimiter{
+		limits: limits,
+		chats:  make(map[string]*bucket),
+	}
+}
+
+// Wait implements Limiter interface.
+func (l *RateLimiter) Wait(ctx context.Context, method, chat string) error {
+	if !strings.HasPrefix(method, "send") && method != "forwardMessage" {
+		return nil
+	}
+	if method == "sendChatAction" {
+		return nil
+	}
+
+	var (
+		b    *bucket
+		rate Rate
+	)
+	if chat != "" {
+		rate = l.limits.Group
+		if !strings.HasPrefix(chat, "-") && !strings.HasPrefix(chat, "@") {
+			rate = l.limits.Private
+		}
+
+		l.mu.Lock()
+		var ok bool
+		b, ok = l.chats[chat]
+		if !ok {
+			l.sweep(time.Now())
+			b = &bucket{}
+			l.chats[chat] = b
+		}
+		delay := b.reserve(rate, time.Now())
+		l.mu.Unlock()
+
+		if err := sleep(ctx, delay); err != nil {
+			l.refund(b, rate)
+			return err
+		}
+	}
+
+	l.mu.Lock()
+	delay := l.global.reserve(l.limits.Global, time.Now())
+	l.mu.Unlock()
+
+	if err := sleep(ctx, delay); err != nil {
+		// the request isn't sent, so it doesn't count
+		l.refund(&l.global, l.limits.Global)
+		if b != nil {
+			l.refund(b, rate)
+		}
+		return err
+	}
+	return nil
+}
+
+func (l *RateLimiter) refund(b *bucket, r Rate) {
+	l.mu.Lock()
+	b.refund(r)
+	l.mu.Unlock()
+}
+
+// sweep removes buckets of the chats which have been idle
+// long enough to be refilled, so the map stays bounded.
+func (l *RateLimiter) sweep(now time.Time) {
+	if len(l.chats) < 1024 {
+		return
+	}
+
+	for chat, b := range l.chats {
+		if now.After(b.full) {
+			delete(l.chats, chat)
+		}
+	}
+}
+
+// bucket is a token bucket, which is full when unused.
+type bucket struct {
+	used bool
+	last time.Time
+	full time.Time // when the bucket is refilled
+
+	// tokens may go below zero, which means the
+	// tokens are reserved by the waiting requests
+	tokens float64
+}
+
+// reserve takes a token from the bucket and returns
+// the time to wait until it is available.
+func (b *bucket) reserve(r Rate, now time.Time) time.Duration {
+	perToken := float64(r.Per) / float64(r.Count)
+
+	if !b.used {
+		b.used = true
+		b.tokens = float64(r.Count)
+	} else if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += float64(elapsed) / perToken
+		if b.tokens > float64(r.Count) {
+			b.tokens = float64(r.Count)
+		}
+	}
+
+	b.last = now
+	b.tokens--
+	b.full = now.Add(time.Duration((float64(r.Count) - b.tokens) * perToken))
+
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens * perToken)
+}
+
+// refund gives back the token reserved by the request,
+// which has not been sent.
+func (b *bucket) refund(r Rate) {
+	perToken := float64(r.Per) / float64(r.Count)
+
+	b.tokens++
+	if b.tokens > float64(r.Count) {
+		b.tokens = float64(r.Count)
+	}
+	b.full = b.full.Add(-time.Duration(perToken))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// limit waits for the bot limiter, if such is set.
+func (b *Bot) limit(ctx context.Context, method string, payload interface{}) error {
+	if b.limiter == nil {
+		return nil
+	}
+
+	var chat string
+	switch p := payload.(type) {
+	case map[string]string:
+		chat = p["chat_id"]
+	case map[string]interface{}:
+		chat, _ = p["chat_id"].(string)
+	}
+
+	return b.limiter.Wait(ctx, method, chat)
+}
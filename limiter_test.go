@@ -0,0 +1,101 @@
+package telebot
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(RateLimits{
+		Private: Rate{Count: 2, Per: 100 * time.Millisecond},
+	})
+	assert.Equal(t, Rate{Count: 30, Per: time.Second}, l.limits.Global)
+	assert.Equal(t, Rate{Count: 20, Per: time.Minute}, l.limits.Group)
+
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		require.NoError(t, l.Wait(ctx, "sendMessage", "1"))
+	}
+	// two of them are burst, the others are throttled
+	assert.True(t, time.Since(start) >= 90*time.Millisecond)
+
+	// other chats and methods are not affected
+	start = time.Now()
+	require.NoError(t, l.Wait(ctx, "sendPhoto", "2"))
+	require.NoError(t, l.Wait(ctx, "sendChatAction", "1"))
+	require.NoError(t, l.Wait(ctx, "getChat", "1"))
+	assert.True(t, time.Since(start) < 50*time.Millisecond)
+
+	// group limit is 20 per minute
+	for i := 0; i < 20; i++ {
+		require.NoError(t, l.Wait(ctx, "sendMessage", "-100"))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, l.Wait(ctx, "sendMessage", "-100"))
+}
+
+func TestRateLimiterCancel(t *testing.T) {
+	l := NewRateLimiter(RateLimits{
+		Private: Rate{Count: 1, Per: 100 * time.Millisecond},
+	})
+
+	require.NoError(t, l.Wait(context.Background(), "sendMessage", "1"))
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, l.Wait(ctx, "sendMessage", "1"))
+
+	// the cancelled request gives its token back, so the next
+	// one waits for a single token, not for two of them
+	require.NoError(t, l.Wait(context.Background(), "sendMessage", "1"))
+	elapsed := time.Since(start)
+	assert.True(t, elapsed >= 90*time.Millisecond, elapsed)
+	assert.True(t, elapsed < 150*time.Millisecond, elapsed)
+}
+
+type testLimiter struct {
+	methods []string
+	chats   []string
+}
+
+func (l *testLimiter) Wait(ctx context.Context, method, chat string) error {
+	l.methods = append(l.methods, method)
+	l.chats = append(l.chats, chat)
+	return nil
+}
+
+func TestBotLimiter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok":true,"result":true}`))
+	}))
+	defer srv.Close()
+
+	l := &testLimiter{}
+
+	b, err := NewBot(Settings{
+		URL:     srv.URL,
+		Client:  srv.Client(),
+		Limiter: l,
+		offline: true,
+	})
+	require.NoError(t, err)
+
+	b.Send(&Chat{ID: 1}, "text")
+	b.Send(&Chat{ID: 2}, &Photo{File: FromReader(strings.NewReader("photo"))})
+	b.Respond(&Callback{})
+
+	assert.Equal(t, []string{"sendMessage", "sendPhoto", "answerCallbackQuery"}, l.methods)
+	assert.Equal(t, []string{"1", "2", ""}, l.chats)
+}
@@ -0,0 +1,138 @@
+package telebot
+
+// HandlerFunc represents a handler of the event with
+// all the endpoint-specific arguments bound to the Context.
+type HandlerFunc func(Context) error
+
+// MiddlewareFunc represents a middleware processing function,
+// which gets called before the endpoint handler is executed.
+//
+// It has to call next to pass the control further, otherwise
+// the handler won't be called.
+//
+// Example:
+//
+//		func Logger(next tb.HandlerFunc) tb.HandlerFunc {
+//			return func(c tb.Context) error {
+//				start := time.Now()
+//				defer func() { log.Println(c.Update().ID, time.Since(start)) }()
+//				return next(c)
+//			}
+//		}
+//
+type MiddlewareFunc func(HandlerFunc) HandlerFunc
+
+// Use adds middleware to the global bot chain. It wraps every
+// handler registered with Handle, no matter the order of the calls.
+//
+// Middleware are executed in the same order they were added,
+// global ones are executed before the endpoint-specific.
+// Should be called before the bot is started.
+func (b *Bot) Use(middleware ...MiddlewareFunc) {
+	b.middleware = append(b.middleware, middleware...)
+}
+
+func applyMiddleware(h HandlerFunc, middleware ...MiddlewareFunc) HandlerFunc {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h
+}
+
+// wrapHandler converts one of the supported handler signatures
+// into HandlerFunc. Besides func(Context) error, the legacy
+// per-update signatures are supported, with or without returning
+// an error. It panics if the handler is bad.
+func wrapHandler(handler interface{}) HandlerFunc {
+	switch h := handler.(type) {
+	case HandlerFunc:
+		return h
+	case func(Context) error:
+		return h
+	case func(*Message):
+		return func(c Context) error {
+			h(c.Message())
+			return nil
+		}
+	case func(*Message) error:
+		return func(c Context) error {
+			return h(c.Message())
+		}
+	case func(*Callback):
+		return func(c Context) error {
+			h(c.Callback())
+			return nil
+		}
+	case func(*Callback) error:
+		return func(c Context) error {
+			return h(c.Callback())
+		}
+	case func(*Query):
+		return func(c Context) error {
+			h(c.Query())
+			return nil
+		}
+	case func(*Query) error:
+		return func(c Context) error {
+			return h(c.Query())
+		}
+	case func(*ChosenInlineResult):
+		return func(c Context) error {
+			h(c.ChosenInlineResult())
+			return nil
+		}
+	case func(*ChosenInlineResult) error:
+		return func(c Context) error {
+			return h(c.ChosenInlineResult())
+		}
+	case func(*ShippingQuery):
+		return func(c Context) error {
+			h(c.ShippingQuery())
+			return nil
+		}
+	case func(*ShippingQuery) error:
+		return func(c Context) error {
+			return h(c.ShippingQuery())
+		}
+	case func(*PreCheckoutQuery):
+		return func(c Context) error {
+			h(c.PreCheckoutQuery())
+			return nil
+		}
+	case func(*PreCheckoutQuery) error:
+		return func(c Context) error {
+			return h(c.PreCheckoutQuery())
+		}
+	case func(*Poll):
+		return func(c Context) error {
+			h(c.Poll())
+			return nil
+		}
+	case func(*Poll) error:
+		return func(c Context) error {
+			return h(c.Poll())
+		}
+	case func(*PollAnswer):
+		return func(c Context) error {
+			h(c.PollAnswer())
+			return nil
+		}
+	case func(*PollAnswer) error:
+		return func(c Context) error {
+			return h(c.PollAnswer())
+		}
+	case func(int64, int64):
+		return func(c Context) error {
+			m := c.Message()
+			h(m.Chat.ID, m.MigrateTo)
+			return nil
+		}
+	case func(int64, int64) error:
+		return func(c Context) error {
+			m := c.Message()
+			return h(m.Chat.ID, m.MigrateTo)
+		}
+	default:
+		panic("telebot: unsupported handler")
+	}
+}
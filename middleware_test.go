@@ -0,0 +1,85 @@
+package telebot
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	b, err := NewBot(Settings{Synchronous: true, offline: true})
+	require.NoError(t, err)
+
+	var trace []string
+	tracer := func(name string) MiddlewareFunc {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(c Context) error {
+				trace = append(trace, name)
+				return next(c)
+			}
+		}
+	}
+
+	b.Handle("/start", func(m *Message) {
+		trace = append(trace, "handler")
+	}, tracer("endpoint"))
+
+	// order of Use and Handle calls doesn't matter
+	b.Use(tracer("first"), tracer("second"))
+
+	b.ProcessUpdate(Update{Message: &Message{Text: "/start"}})
+	assert.Equal(t, []string{"first", "second", "endpoint", "handler"}, trace)
+
+	t.Run("all handler kinds", func(t *testing.T) {
+		b.Handle("\funique", func(c *Callback) {
+			assert.Equal(t, "data", c.Data)
+		})
+		b.Handle(OnMigration, func(from, to int64) {
+			assert.Equal(t, int64(1), from)
+			assert.Equal(t, int64(2), to)
+		})
+		b.Handle(OnQuery, func(q *Query) {
+			assert.Equal(t, "query", q.Text)
+		})
+
+		trace = nil
+		b.ProcessUpdate(Update{Callback: &Callback{Data: "\funique|data"}})
+		b.ProcessUpdate(Update{Message: &Message{Chat: &Chat{ID: 1}, MigrateTo: 2}})
+		b.ProcessUpdate(Update{Query: &Query{Text: "query"}})
+		assert.Equal(t, []string{"first", "second", "first", "second", "first", "second"}, trace)
+	})
+
+	t.Run("break the chain", func(t *testing.T) {
+		var reported error
+		b.reporter = func(err error) { reported = err }
+
+		b.Use(func(next HandlerFunc) HandlerFunc {
+			return func(c Context) error {
+				c.Set("user", c.Message().Sender)
+				if c.Message().Sender == nil {
+					return errors.New("unauthorized")
+				}
+				return next(c)
+			}
+		})
+
+		var called bool
+		b.Handle(OnText, func(m *Message) { called = true })
+
+		b.ProcessUpdate(Update{Message: &Message{Text: "text"}})
+		assert.False(t, called)
+		assert.EqualError(t, errors.Unwrap(reported), "unauthorized")
+
+		b.ProcessUpdate(Update{Message: &Message{Text: "text", Sender: &User{}}})
+		assert.True(t, called)
+	})
+}
+
+func TestBotHandleBad(t *testing.T) {
+	b, err := NewBot(Settings{offline: true})
+	require.NoError(t, err)
+
+	assert.Panics(t, func() { b.Handle(OnText, func() {}) })
+}
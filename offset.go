@@ -0,0 +1,77 @@
+package telebot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// OffsetStore persists the ID of the last processed update,
+// so LongPoller continues from it after restart.
+type OffsetStore interface {
+	// Load returns the last committed update ID,
+	// zero if nothing has been committed yet.
+	Load() (int, error)
+
+	// Commit saves the ID of the last processed update.
+	Commit(id int) error
+}
+
+// FileOffsetStore is an OffsetStore keeping
+// the update ID in a plain text file.
+type FileOffsetStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileOffsetStore creates an OffsetStore using the file
+// at the path. The file is created on the first commit.
+func NewFileOffsetStore(path string) *FileOffsetStore {
+	return &FileOffsetStore{path: path}
+}
+
+// Load implements OffsetStore interface.
+func (s *FileOffsetStore) Load() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	id, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, wrapError(err)
+	}
+	return id, nil
+}
+
+// Commit implements OffsetStore interface. The file is
+// replaced atomically, so it's never left half-written.
+func (s *FileOffsetStore) Commit(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return wrapError(err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(strconv.Itoa(id) + "\n"); err != nil {
+		tmp.Close()
+		return wrapError(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return wrapError(err)
+	}
+
+	return wrapError(os.Rename(tmp.Name(), s.path))
+}
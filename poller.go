@@ -1,7 +1,11 @@
 package telebot
 
 import (
+	"context"
+	"sync"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
 // Poller is a provider of Updates.
@@ -14,8 +18,10 @@ type Poller interface {
 	// subscription channel and start polling
 	// for Updates immediately.
 	//
-	// Poller must listen for stop constantly and close
-	// it as soon as it's done polling.
+	// Poller must listen for stop constantly and return
+	// as soon as it's closed. Updates received before that
+	// should still be sent, the bot keeps reading them until
+	// Poll returns.
 	Poll(b *Bot, updates chan Update, stop chan struct{})
 }
 
@@ -47,22 +53,42 @@ func (p *MiddlewarePoller) Poll(b *Bot, dest chan Update, stop chan struct{}) {
 
 	middle := make(chan Update, p.Capacity)
 	stopPoller := make(chan struct{})
+	polled := make(chan struct{})
 
-	go p.Poller.Poll(b, middle, stopPoller)
+	go func() {
+		p.Poller.Poll(b, middle, stopPoller)
+		close(polled)
+	}()
 
 	for {
 		select {
 		case <-stop:
 			close(stopPoller)
-			return
-		case upd := <-middle:
-			if p.Filter(&upd) {
-				dest <- upd
+			stop = nil
+		case <-polled:
+			for {
+				select {
+				case upd := <-middle:
+					p.filter(b, upd, dest)
+				default:
+					return
+				}
 			}
+		case upd := <-middle:
+			p.filter(b, upd, dest)
 		}
 	}
 }
 
+func (p *MiddlewarePoller) filter(b *Bot, upd Update, dest chan Update) {
+	if p.Filter(&upd) {
+		dest <- upd
+	} else {
+		// filtered updates are considered handled
+		b.untrack(upd.ID)
+	}
+}
+
 // LongPoller is a classic LongPoller with timeout.
 type LongPoller struct {
 	Limit        int
@@ -86,10 +112,142 @@ type LongPoller struct {
 	// 		poll_answer
 	//
 	AllowedUpdates []string
+
+	// Offset keeps the ID of the last processed update between
+	// restarts. It's loaded when the polling starts, and is
+	// preferred to LastUpdateID if it's greater.
+	//
+	// By default, the offset is committed as soon as the
+	// updates are handed to the bot, so the ones not handled
+	// before a crash are lost.
+	Offset OffsetStore
+
+	// CommitOnHandled makes the poller commit the offset only
+	// after the handlers of the updates are done, and only then
+	// request the next updates, so every update is handled at
+	// least once, even after a crash.
+	CommitOnHandled bool
+
+	// Dedup skips the updates, which have already been received,
+	// e.g. when the offset hasn't been committed before a restart.
+	Dedup Deduplicator
+
+	// Backoff is the wait after the first failed request,
+	// it doubles on each next failure in a row, up to
+	// MaxBackoff. Default: 1 second
+	Backoff time.Duration
+
+	// MaxBackoff limits the wait between failed requests.
+	// Default: 1 minute
+	MaxBackoff time.Duration
+
+	mu     sync.Mutex
+	status PollerStatus
+}
+
+// PollerStatus describes the health of the poller.
+type PollerStatus struct {
+	// Healthy is true if the last request succeeded.
+	Healthy bool
+
+	// Failures is the number of failed requests in a row.
+	Failures int
+
+	// LastSuccess is the time of the last successful request.
+	LastSuccess time.Time
+
+	// LastError is the error of the last failed request.
+	LastError error
+
+	// Fatal is true if polling has been stopped by an error,
+	// which can't be recovered from, see IsFatal.
+	Fatal bool
+}
+
+// IsFatal reports whether polling can't continue after the
+// error: the token is invalid, or a webhook is set.
+func IsFatal(err error) bool {
+	return errors.Is(err, ErrUnauthorized) || errors.Is(err, ErrWebhookActive)
+}
+
+// Status returns the current health status of the poller.
+// It's safe to be called while polling.
+func (p *LongPoller) Status() PollerStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status
+}
+
+func (p *LongPoller) backoff(failures int) time.Duration {
+	backoff, max := p.Backoff, p.MaxBackoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	if max <= 0 {
+		max = time.Minute
+	}
+
+	for i := 1; i < failures && backoff < max; i++ {
+		backoff *= 2
+	}
+	if backoff > max {
+		backoff = max
+	}
+	return backoff
+}
+
+// succeeded resets the status after a successful request.
+func (p *LongPoller) succeeded() {
+	p.mu.Lock()
+	p.status = PollerStatus{
+		Healthy:     true,
+		LastSuccess: time.Now(),
+	}
+	p.mu.Unlock()
 }
 
-// Poll does long polling.
+// failed updates the status after a failed request
+// and returns the number of failures in a row.
+func (p *LongPoller) failed(err error) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.status.Healthy = false
+	p.status.Failures++
+	p.status.LastError = err
+	p.status.Fatal = IsFatal(err)
+	return p.status.Failures
+}
+
+// Poll does long polling. The pending request is
+// cancelled as soon as the poller is stopped.
+//
+// Failed requests are repeated with a backoff, unless the
+// error is fatal. Then the poller returns, and the error is
+// returned by Bot.Start.
 func (p *LongPoller) Poll(b *Bot, dest chan Update, stop chan struct{}) {
+	ctx, cancel := context.WithCancel(b.context())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	b = b.WithContext(ctx)
+
+	if p.Offset != nil {
+		id, err := p.Offset.Load()
+		if err != nil {
+			b.debug(err)
+		} else if id > p.LastUpdateID {
+			p.LastUpdateID = id
+		}
+	}
+
 	for {
 		select {
 		case <-stop:
@@ -99,14 +257,52 @@ func (p *LongPoller) Poll(b *Bot, dest chan Update, stop chan struct{}) {
 
 		updates, err := b.getUpdates(p.LastUpdateID+1, p.Limit, p.Timeout, p.AllowedUpdates)
 		if err != nil {
-			b.debug(err)
-			b.debug(ErrCouldNotUpdate)
+			if ctx.Err() != nil {
+				return
+			}
+
+			failures := p.failed(err)
+			if IsFatal(err) {
+				b.pollerFailed(err)
+				return
+			}
+
+			b.debug(errors.Wrap(err, ErrCouldNotUpdate.Error()))
+			if sleep(ctx, p.backoff(failures)) != nil {
+				return
+			}
+			continue
+		}
+		p.succeeded()
+
+		if len(updates) == 0 {
 			continue
 		}
 
 		for _, update := range updates {
+			if b.duplicate(p.Dedup, update) {
+				p.LastUpdateID = update.ID
+				continue
+			}
+			if p.CommitOnHandled {
+				b.track(update.ID)
+			}
 			p.LastUpdateID = update.ID
 			dest <- update
 		}
+
+		if p.CommitOnHandled && !b.waitHandled(stop) {
+			return
+		}
+		p.commit(b)
+	}
+}
+
+func (p *LongPoller) commit(b *Bot) {
+	if p.Offset == nil {
+		return
+	}
+	if err := p.Offset.Commit(p.LastUpdateID); err != nil {
+		b.debug(err)
 	}
 }
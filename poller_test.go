@@ -1,9 +1,22 @@
 package telebot
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type testPoller struct {
@@ -65,3 +78,158 @@ func TestMiddlewarePoller(t *testing.T) {
 	assert.Contains(t, ids, 1)
 	assert.Contains(t, ids, 2)
 }
+
+func TestLongPollerOffset(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		offsets []int
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params struct {
+			Offset string `json:"offset"`
+		}
+		json.NewDecoder(r.Body).Decode(&params)
+		offset, _ := strconv.Atoi(params.Offset)
+
+		mu.Lock()
+		offsets = append(offsets, offset)
+		mu.Unlock()
+
+		if offset > 2 {
+			time.Sleep(10 * time.Millisecond)
+			w.Write([]byte(`{"ok":true,"result":[]}`))
+			return
+		}
+
+		w.Write([]byte(`{"ok":true,"result":[
+			{"update_id":1,"message":{"message_id":1,"text":"a","chat":{"id":1}}},
+			{"update_id":2,"message":{"message_id":2,"text":"b","chat":{"id":2}}}
+		]}`))
+	}))
+	defer srv.Close()
+
+	lastOffset := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return offsets[len(offsets)-1]
+	}
+
+	dir, err := ioutil.TempDir("", "telebot")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store := NewFileOffsetStore(filepath.Join(dir, "offset"))
+
+	b, err := NewBot(Settings{
+		URL:    srv.URL,
+		Client: srv.Client(),
+		Poller: &LongPoller{
+			Offset:          store,
+			CommitOnHandled: true,
+		},
+		offline: true,
+	})
+	require.NoError(t, err)
+
+	var started int32
+	release := make(chan struct{})
+
+	b.Handle(OnText, func(c Context) error {
+		atomic.AddInt32(&started, 1)
+		<-release
+		return nil
+	})
+
+	go b.Start()
+
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&started) == 2
+	}, time.Second, time.Millisecond)
+
+	// the next updates are not requested until the handlers are done
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 1, lastOffset())
+
+	id, err := store.Load()
+	require.NoError(t, err)
+	assert.Equal(t, 0, id)
+
+	close(release)
+	require.Eventually(t, func() bool {
+		id, _ := store.Load()
+		return id == 2
+	}, time.Second, time.Millisecond)
+
+	_, err = b.Shutdown(context.Background())
+	require.NoError(t, err)
+
+	// polling continues from the committed offset
+	b.Poller = &LongPoller{Offset: store}
+	go b.Start()
+
+	require.Eventually(t, func() bool {
+		return lastOffset() == 3
+	}, time.Second, time.Millisecond)
+	b.Stop()
+}
+
+func TestLongPollerBackoff(t *testing.T) {
+	p := &LongPoller{}
+	assert.Equal(t, time.Second, p.backoff(1))
+	assert.Equal(t, 4*time.Second, p.backoff(3))
+	assert.Equal(t, time.Minute, p.backoff(100))
+
+	p = &LongPoller{Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	assert.Equal(t, 20*time.Millisecond, p.backoff(2))
+	assert.Equal(t, 50*time.Millisecond, p.backoff(4))
+
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&requests, 1) {
+		case 1, 2:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"ok":false,"error_code":500,"description":"Internal Server Error"}`))
+		case 3:
+			w.Write([]byte(`{"ok":true,"result":[]}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"ok":false,"error_code":401,"description":"Unauthorized"}`))
+		}
+	}))
+	defer srv.Close()
+
+	poller := &LongPoller{Backoff: 10 * time.Millisecond}
+
+	b, err := NewBot(Settings{
+		URL:      srv.URL,
+		Client:   srv.Client(),
+		Poller:   poller,
+		Reporter: func(error) {},
+		offline:  true,
+	})
+	require.NoError(t, err)
+
+	start := time.Now()
+	err = b.Start()
+	assert.True(t, time.Since(start) >= 30*time.Millisecond)
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+	assert.Equal(t, int32(4), atomic.LoadInt32(&requests))
+
+	status := poller.Status()
+	assert.False(t, status.Healthy)
+	assert.True(t, status.Fatal)
+	assert.Equal(t, 1, status.Failures)
+	assert.False(t, status.LastSuccess.IsZero())
+	assert.True(t, errors.Is(status.LastError, ErrUnauthorized))
+
+	_, err = b.Shutdown(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestIsFatal(t *testing.T) {
+	assert.True(t, IsFatal(ErrUnauthorized))
+	assert.True(t, IsFatal(NewAPIError(409, ErrWebhookActive.Description+"; use deleteWebhook to delete the webhook first")))
+	assert.False(t, IsFatal(NewAPIError(409, "Conflict: terminated by other getUpdates request")))
+	assert.False(t, IsFatal(ErrInternal))
+}
@@ -0,0 +1,315 @@
+package telebot
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Record is a line of the recording: either an incoming
+// update, or an outgoing Bot API call.
+type Record struct {
+	Time   time.Time     `json:"time"`
+	Update *Update       `json:"update,omitempty"`
+	Call   *RecordedCall `json:"call,omitempty"`
+}
+
+// RecordedCall is a Bot API call made while recording.
+type RecordedCall struct {
+	// UpdateID is the ID of the update the call
+	// was made in response to.
+	UpdateID int `json:"update_id,omitempty"`
+
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+
+	// Files contains the fields of the uploaded files.
+	Files []string `json:"files,omitempty"`
+
+	// Response is the raw response of the server.
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// ReadRecords reads the recording made with RecordPoller.
+func ReadRecords(r io.Reader) ([]Record, error) {
+	var (
+		records []Record
+		scanner = bufio.NewScanner(r)
+	)
+
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, errors.Wrapf(err, "telebot: broken record %d", len(records)+1)
+		}
+		records = append(records, rec)
+	}
+
+	return records, wrapError(scanner.Err())
+}
+
+// RecordPoller wraps a poller and writes every update it
+// receives, along with the Bot API calls made while handling
+// the updates, to the writer as JSON lines. The calls of the
+// poller itself, like getUpdates, are not recorded. The
+// recording can be fed back to the bot with ReplayPoller.
+//
+// Example:
+//
+//		file, _ := os.Create("updates.jsonl")
+//		defer file.Close()
+//
+//		poller := tb.NewRecordPoller(&tb.LongPoller{Timeout: 10 * time.Second}, file)
+//
+// Write errors are reported, and the bot keeps polling.
+type RecordPoller struct {
+	Poller Poller
+	Writer io.Writer
+
+	mu sync.Mutex
+}
+
+// NewRecordPoller creates a poller recording the updates to w.
+func NewRecordPoller(original Poller, w io.Writer) *RecordPoller {
+	return &RecordPoller{
+		Poller: original,
+		Writer: w,
+	}
+}
+
+// Poll records the updates of the wrapped poller.
+func (p *RecordPoller) Poll(b *Bot, dest chan Update, stop chan struct{}) {
+	observer := &callObserver{fn: func(call *RecordedCall) {
+		p.write(b, Record{Time: time.Now(), Call: call})
+	}}
+
+	b.setObserver(observer)
+	defer b.unsetObserver(observer)
+
+	middle := make(chan Update)
+	polled := make(chan struct{})
+
+	go func() {
+		p.Poller.Poll(b, middle, stop)
+		close(polled)
+	}()
+
+	for {
+		select {
+		case upd := <-middle:
+			p.write(b, Record{Time: time.Now(), Update: &upd})
+			dest <- upd
+		case <-polled:
+			return
+		}
+	}
+}
+
+func (p *RecordPoller) write(b *Bot, rec Record) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		b.debug(err)
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, err := p.Writer.Write(append(data, '\n')); err != nil {
+		b.debug(err)
+	}
+}
+
+// ReplayPoller feeds the updates of a recording to the bot,
+// so a production issue could be reproduced locally, or a
+// recording could become a regression test. The calls of the
+// recording are ignored.
+//
+// The poller returns once all the updates are sent, while the
+// bot keeps running until stopped, use Done to wait for it.
+type ReplayPoller struct {
+	Records []Record
+
+	// Speed is the pace of the replay relative to the recording:
+	// 1 keeps the original timing, 10 is ten times faster.
+	// Zero sends the updates with no delays.
+	Speed float64
+
+	// WaitHandled makes the poller wait until the update and
+	// all the handlers it has started are done before sending
+	// the next one, so the replay is deterministic.
+	WaitHandled bool
+
+	mu       sync.Mutex
+	done     chan struct{}
+	finished bool
+}
+
+// NewReplayPoller creates a poller replaying the recording read from r.
+func NewReplayPoller(r io.Reader) (*ReplayPoller, error) {
+	records, err := ReadRecords(r)
+	if err != nil {
+		return nil, err
+	}
+	return &ReplayPoller{Records: records}, nil
+}
+
+// Done returns a channel, which is closed once
+// all the updates of the recording are sent.
+func (p *ReplayPoller) Done() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.done == nil {
+		p.done = make(chan struct{})
+	}
+	return p.done
+}
+
+func (p *ReplayPoller) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.done == nil {
+		p.done = make(chan struct{})
+	}
+	if !p.finished {
+		p.finished = true
+		close(p.done)
+	}
+}
+
+// Poll sends the recorded updates to the bot.
+func (p *ReplayPoller) Poll(b *Bot, dest chan Update, stop chan struct{}) {
+	var updates []Record
+	for _, rec := range p.Records {
+		if rec.Update != nil {
+			updates = append(updates, rec)
+		}
+	}
+	sort.SliceStable(updates, func(i, j int) bool {
+		return updates[i].Time.Before(updates[j].Time)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for i, rec := range updates {
+		if i > 0 && p.Speed > 0 {
+			delay := rec.Time.Sub(updates[i-1].Time)
+			if sleep(ctx, time.Duration(float64(delay)/p.Speed)) != nil {
+				return
+			}
+		}
+
+		var handled <-chan struct{}
+		if p.WaitHandled {
+			handled = b.track(rec.Update.ID)
+		}
+
+		select {
+		case dest <- *rec.Update:
+		case <-stop:
+			if handled != nil {
+				b.untrack(rec.Update.ID)
+			}
+			return
+		}
+
+		if handled != nil {
+			select {
+			case <-handled:
+			case <-stop:
+				return
+			}
+		}
+	}
+
+	p.finish()
+}
+
+type (
+	updateIDKey struct{}
+
+	// callObserver is notified of the Bot API calls.
+	callObserver struct {
+		fn func(*RecordedCall)
+	}
+)
+
+func (b *Bot) setObserver(o *callObserver) {
+	b.state.mu.Lock()
+	b.state.observer = o
+	b.state.mu.Unlock()
+}
+
+func (b *Bot) unsetObserver(o *callObserver) {
+	b.state.mu.Lock()
+	if b.state.observer == o {
+		b.state.observer = nil
+	}
+	b.state.mu.Unlock()
+}
+
+func (b *Bot) getObserver() *callObserver {
+	b.state.mu.Lock()
+	defer b.state.mu.Unlock()
+	return b.state.observer
+}
+
+// observeUpdate returns a view of the bot, which relates the calls
+// made with it to the update, if the calls are being observed.
+func (b *Bot) observeUpdate(upd Update) *Bot {
+	if b.getObserver() == nil {
+		return b
+	}
+	return b.WithContext(context.WithValue(b.context(), updateIDKey{}, upd.ID))
+}
+
+// observe notifies the observer of the call, if there is such
+// and the call is made while handling an update.
+func (b *Bot) observe(ctx context.Context, method string, params interface{}, files []string, data []byte, err error) {
+	o := b.getObserver()
+	if o == nil {
+		return
+	}
+
+	id, ok := ctx.Value(updateIDKey{}).(int)
+	if !ok {
+		return
+	}
+
+	call := &RecordedCall{UpdateID: id, Method: method, Files: files}
+
+	if params != nil {
+		call.Params, _ = json.Marshal(params)
+	}
+	if json.Valid(data) {
+		call.Response = data
+	}
+	if err != nil {
+		call.Error = err.Error()
+	}
+
+	o.fn(call)
+}
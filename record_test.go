@@ -0,0 +1,142 @@
+package telebot
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordPoller(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok":true,"result":{"message_id":1,"chat":{"id":1}}}`))
+	}))
+	defer srv.Close()
+
+	now := time.Now()
+	replay := &ReplayPoller{
+		Records: []Record{
+			{Time: now, Update: &Update{ID: 1, Message: &Message{Text: "a", Chat: &Chat{ID: 1}}}},
+			{Time: now, Call: &RecordedCall{Method: "sendMessage"}},
+			{Time: now, Update: &Update{ID: 2, Message: &Message{Text: "b", Chat: &Chat{ID: 1}}}},
+		},
+		WaitHandled: true,
+	}
+
+	var buf bytes.Buffer
+	b, err := NewBot(Settings{
+		URL:     srv.URL,
+		Client:  srv.Client(),
+		Poller:  NewRecordPoller(replay, &buf),
+		offline: true,
+	})
+	require.NoError(t, err)
+
+	b.Handle(OnText, func(c Context) error {
+		return c.Send(c.Text())
+	})
+
+	go b.Start()
+	select {
+	case <-replay.Done():
+	case <-time.After(time.Second):
+		t.Fatal("replay isn't done")
+	}
+	_, err = b.Shutdown(context.Background())
+	require.NoError(t, err)
+
+	records, err := ReadRecords(&buf)
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+
+	assert.Equal(t, 1, records[0].Update.ID)
+	assert.Equal(t, "a", records[0].Update.Message.Text)
+
+	call := records[1].Call
+	require.NotNil(t, call)
+	assert.Equal(t, 1, call.UpdateID)
+	assert.Equal(t, "sendMessage", call.Method)
+	assert.JSONEq(t, `{"chat_id":"1","text":"a"}`, string(call.Params))
+	assert.JSONEq(t, `{"ok":true,"result":{"message_id":1,"chat":{"id":1}}}`, string(call.Response))
+
+	assert.Equal(t, 2, records[2].Update.ID)
+	assert.Equal(t, 2, records[3].Call.UpdateID)
+
+	// no calls are recorded once the poller is stopped
+	_, err = b.Send(&Chat{ID: 1}, "c")
+	require.NoError(t, err)
+	assert.Zero(t, buf.Len())
+}
+
+func TestRecordPollerCalls(t *testing.T) {
+	var polls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/getUpdates") {
+			atomic.AddInt32(&polls, 1)
+			w.Write([]byte(`{"ok":true,"result":[]}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true,"result":true}`))
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	b, err := NewBot(Settings{
+		URL:     srv.URL,
+		Client:  srv.Client(),
+		Poller:  NewRecordPoller(&LongPoller{}, &buf),
+		offline: true,
+	})
+	require.NoError(t, err)
+
+	go b.Start()
+	time.Sleep(50 * time.Millisecond)
+	_, err = b.Shutdown(context.Background())
+	require.NoError(t, err)
+
+	assert.NotZero(t, atomic.LoadInt32(&polls))
+	assert.NotContains(t, buf.String(), "getUpdates")
+	assert.Zero(t, buf.Len())
+}
+
+func TestReplayPoller(t *testing.T) {
+	_, err := NewReplayPoller(strings.NewReader("{}\n{"))
+	assert.Error(t, err)
+
+	replay, err := NewReplayPoller(strings.NewReader(
+		`{"time":"2020-01-01T00:00:00.2Z","update":{"update_id":2,"message":{"text":"b"}}}` + "\n" +
+			`{"time":"2020-01-01T00:00:00Z","update":{"update_id":1,"message":{"text":"a"}}}` + "\n",
+	))
+	require.NoError(t, err)
+	replay.Speed = 2
+
+	b, err := NewBot(Settings{Poller: replay, Synchronous: true, offline: true})
+	require.NoError(t, err)
+
+	var texts []string
+	b.Handle(OnText, func(c Context) error {
+		texts = append(texts, c.Text())
+		return nil
+	})
+
+	start := time.Now()
+	go b.Start()
+
+	select {
+	case <-replay.Done():
+	case <-time.After(time.Second):
+		t.Fatal("replay isn't done")
+	}
+	_, err = b.Shutdown(context.Background())
+	require.NoError(t, err)
+
+	assert.True(t, time.Since(start) >= 100*time.Millisecond)
+	assert.Equal(t, []string{"a", "b"}, texts)
+}
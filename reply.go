@@ -0,0 +1,162 @@
+package telebot
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+type (
+	replyKey     struct{}
+	replyMarkKey struct{}
+)
+
+// webhookReply holds the call, which is sent in the webhook response.
+type webhookReply struct {
+	mu      sync.Mutex
+	closed  bool
+	method  string
+	payload interface{}
+}
+
+// take stores the call, if there is no other one,
+// and the response hasn't been written yet.
+func (r *webhookReply) take(method string, payload interface{}) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return false
+	}
+
+	r.closed = true
+	r.method, r.payload = method, payload
+	return true
+}
+
+// body closes the reply and returns the response body
+// with the call, or nil if there is no such.
+func (r *webhookReply) body() ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+	if r.method == "" {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(r.payload)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	params := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, wrapError(err)
+	}
+
+	params["method"], _ = json.Marshal(r.method)
+	return json.Marshal(params)
+}
+
+// WebhookReply returns a copy of the context, which sends the
+// next Bot API call in the response to the webhook request,
+// saving a round trip. The call returns no result, so Send and
+// Reply return a nil message. Calls uploading files, and the
+// ones made after the response is written, are sent as usual.
+//
+// It only works for the updates received with Webhook.Reply
+// or processed with ProcessUpdateReply, otherwise the context
+// is returned as is.
+//
+// Example:
+//
+//		b.Handle(tb.OnText, func(c tb.Context) error {
+//			return tb.WebhookReply(c).Send(c.Text())
+//		})
+//
+func WebhookReply(c Context) Context {
+	b := c.Bot()
+
+	ctx := b.context()
+	if _, ok := ctx.Value(replyKey{}).(*webhookReply); !ok {
+		return c
+	}
+
+	view := b.WithContext(context.WithValue(ctx, replyMarkKey{}, true))
+	replyCtx := view.NewContext(c.Update())
+
+	if nc, ok := c.(*nativeContext); ok {
+		nc.lock.RLock()
+		for k, v := range nc.store {
+			replyCtx.Set(k, v)
+		}
+		nc.lock.RUnlock()
+	}
+
+	return replyCtx
+}
+
+// replyInline stores the call in the webhook reply, if the
+// context is marked with WebhookReply.
+func replyInline(ctx context.Context, method string, payload interface{}) bool {
+	if marked, _ := ctx.Value(replyMarkKey{}).(bool); !marked {
+		return false
+	}
+
+	reply, ok := ctx.Value(replyKey{}).(*webhookReply)
+	return ok && reply.take(method, payload)
+}
+
+// ProcessUpdateReply processes the update synchronously, as if the
+// bot were Synchronous, and returns the body of the webhook response
+// with the call made with WebhookReply, or nil if there is no such.
+//
+// It's meant for the environments, which handle webhook requests
+// on their own, like AWS Lambda.
+func (b *Bot) ProcessUpdateReply(upd Update) ([]byte, error) {
+	reply := &webhookReply{}
+	b.processUpdateReply(upd, reply)
+	return reply.body()
+}
+
+// expectReply makes the update, once it's processed,
+// store the call made with WebhookReply in the reply.
+func (b *Bot) expectReply(id int, reply *webhookReply) {
+	b.state.mu.Lock()
+	defer b.state.mu.Unlock()
+
+	if b.state.replies == nil {
+		b.state.replies = make(map[int]*webhookReply)
+	}
+	b.state.replies[id] = reply
+}
+
+// takeReply removes the reply expected by the update.
+func (b *Bot) takeReply(id int) *webhookReply {
+	b.state.mu.Lock()
+	defer b.state.mu.Unlock()
+
+	reply, ok := b.state.replies[id]
+	if ok {
+		delete(b.state.replies, id)
+	}
+	return reply
+}
+
+// replyUpdate returns a view of the bot, which replies
+// with the call made with WebhookReply, if the update
+// is expected to.
+func (b *Bot) replyUpdate(upd Update) *Bot {
+	reply := b.takeReply(upd.ID)
+	if reply == nil {
+		return b
+	}
+	return b.WithContext(context.WithValue(b.context(), replyKey{}, reply))
+}
+
+func (b *Bot) processUpdateReply(upd Update, reply *webhookReply) {
+	view := b.WithContext(context.WithValue(b.context(), replyKey{}, reply))
+	view.synchronous = true
+	view.ProcessUpdate(upd)
+}
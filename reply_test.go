@@ -0,0 +1,133 @@
+package telebot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessUpdateReply(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		methods []string
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		methods = append(methods, r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:])
+		mu.Unlock()
+		w.Write([]byte(`{"ok":true,"result":{"message_id":1}}`))
+	}))
+	defer srv.Close()
+
+	b, err := NewBot(Settings{URL: srv.URL, Client: srv.Client(), offline: true})
+	require.NoError(t, err)
+
+	b.Handle(OnText, func(c Context) error {
+		c.Set("key", "value")
+
+		reply := WebhookReply(c)
+		assert.Equal(t, "value", reply.Get("key"))
+
+		if err := reply.Send("echo: " + c.Text()); err != nil {
+			return err
+		}
+		// only the first call is sent in the response
+		return reply.Send("second")
+	})
+	b.Handle("/plain", func(c Context) error {
+		return c.Send("plain")
+	})
+
+	upd := Update{ID: 1, Message: &Message{Text: "hi", Chat: &Chat{ID: 1}}}
+
+	body, err := b.ProcessUpdateReply(upd)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"method":"sendMessage","chat_id":"1","text":"echo: hi"}`, string(body))
+	assert.Equal(t, []string{"sendMessage"}, methods)
+
+	body, err = b.ProcessUpdateReply(Update{ID: 2, Message: &Message{Text: "/plain", Chat: &Chat{ID: 1}}})
+	require.NoError(t, err)
+	assert.Nil(t, body)
+	assert.Equal(t, []string{"sendMessage", "sendMessage"}, methods)
+
+	// outside of the webhook reply, calls are sent as usual
+	b.synchronous = true
+	b.ProcessUpdate(upd)
+	assert.Len(t, methods, 4)
+}
+
+func TestWebhookReply(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok":true,"result":true}`))
+	}))
+	defer srv.Close()
+
+	h := &Webhook{Reply: true, HandleTimeout: 10 * time.Millisecond}
+
+	b, err := NewBot(Settings{
+		URL:    srv.URL,
+		Client: srv.Client(),
+		Poller: NewMiddlewarePoller(h, func(upd *Update) bool {
+			return upd.ID != 3
+		}),
+		offline: true,
+	})
+	require.NoError(t, err)
+
+	release := make(chan struct{})
+
+	b.Handle(OnCallback, func(c Context) error {
+		return WebhookReply(c).Respond(&CallbackResponse{Text: "done"})
+	})
+	b.Handle(OnText, func(c Context) error {
+		<-release
+		return nil
+	})
+	b.Handle("/filtered", func(c Context) error {
+		return WebhookReply(c).Send("filtered")
+	})
+
+	go b.Start()
+	defer b.Stop()
+
+	serve := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	var w *httptest.ResponseRecorder
+	require.Eventually(t, func() bool {
+		w = serve(`{"update_id":1,"callback_query":{"id":"42","data":"data"}}`)
+		return w.Code == http.StatusOK
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "answerCallbackQuery", resp["method"])
+	assert.Equal(t, "42", resp["callback_query_id"])
+	assert.Equal(t, "done", resp["text"])
+
+	// the response is written after the timeout
+	w = serve(`{"update_id":2,"message":{"text":"hi","chat":{"id":1}}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Body.String())
+	close(release)
+
+	// the wrapping poller filters the update out
+	w = serve(`{"update_id":3,"message":{"text":"/filtered","chat":{"id":1}}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Nil(t, b.takeReply(3))
+}
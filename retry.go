@@ -0,0 +1,171 @@
+package telebot
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RetryPolicy describes how failed Bot API requests are retried.
+//
+// Requests rejected with FloodError are retried after the
+// RetryAfter period Telegram asks to wait, since they have not
+// been performed. On server and network errors the request
+// might have been performed anyway, so only idempotent methods
+// are retried, with an exponential backoff.
+//
+// Example:
+//
+//		b, err := tb.NewBot(tb.Settings{
+//			Token: token,
+//			Retry: &tb.RetryPolicy{
+//				MaxAttempts: 5,
+//				MaxWait:     time.Minute,
+//				Jitter:      time.Second,
+//			},
+//		})
+//
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts,
+	// including the first one. Default: 3
+	MaxAttempts int
+
+	// MaxWait limits the single wait between the attempts.
+	// If Telegram asks to wait longer, the error is returned
+	// immediately. Default: 1 minute
+	MaxWait time.Duration
+
+	// Backoff is the wait before the first retry on server
+	// and network errors, it doubles on each next attempt.
+	// Default: 1 second
+	Backoff time.Duration
+
+	// Jitter is the upper bound of the random duration
+	// added to every wait.
+	Jitter time.Duration
+
+	// Idempotent reports whether the method is safe to be repeated
+	// after server or network error. By default, these are
+	// get*, set*, edit* and delete* methods, except getUpdates,
+	// which is retried by the LongPoller on its own.
+	Idempotent func(method string) bool
+
+	// OnRetry is called before every retry.
+	OnRetry func(RetryEvent)
+}
+
+// RetryEvent describes the failed attempt, which is going to be retried.
+type RetryEvent struct {
+	Method  string
+	Attempt int // the number of the failed attempt, starting from 1
+	Wait    time.Duration
+	Err     error
+}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts > 0 {
+		return p.MaxAttempts
+	}
+	return 3
+}
+
+func (p *RetryPolicy) maxWait() time.Duration {
+	if p.MaxWait > 0 {
+		return p.MaxWait
+	}
+	return time.Minute
+}
+
+func (p *RetryPolicy) backoff() time.Duration {
+	if p.Backoff > 0 {
+		return p.Backoff
+	}
+	return time.Second
+}
+
+func (p *RetryPolicy) idempotent(method string) bool {
+	if p.Idempotent != nil {
+		return p.Idempotent(method)
+	}
+	if method == "getUpdates" {
+		return false
+	}
+	for _, prefix := range []string{"get", "set", "edit", "delete"} {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// wait returns the duration to wait before the next attempt,
+// or false if the request shouldn't be retried.
+func (p *RetryPolicy) wait(method string, attempt int, err error) (time.Duration, bool) {
+	var wait time.Duration
+
+	var flood FloodError
+	if errors.As(err, &flood) {
+		wait = time.Duration(flood.RetryAfter) * time.Second
+	} else if isTemporary(err) && p.idempotent(method) {
+		wait = p.backoff() << uint(attempt-1)
+	} else {
+		return 0, false
+	}
+
+	if p.Jitter > 0 {
+		wait += time.Duration(rand.Int63n(int64(p.Jitter)))
+	}
+	if wait > p.maxWait() {
+		return 0, false
+	}
+	return wait, true
+}
+
+// isTemporary reports whether the error is caused
+// by Telegram servers or network failure.
+func isTemporary(err error) bool {
+	if errorCode(err) >= http.StatusInternalServerError {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// withRetry calls the request according to the bot retry policy.
+// If replayable is false, the request is called only once.
+func (b *Bot) withRetry(ctx context.Context, method string, replayable bool, request func() ([]byte, error)) ([]byte, error) {
+	p := b.retry
+	if p == nil || !replayable {
+		return request()
+	}
+
+	for attempt := 1; ; attempt++ {
+		data, err := request()
+		if err == nil || attempt >= p.maxAttempts() || ctx.Err() != nil {
+			return data, err
+		}
+
+		wait, ok := p.wait(method, attempt, err)
+		if !ok {
+			return data, err
+		}
+
+		if p.OnRetry != nil {
+			p.OnRetry(RetryEvent{
+				Method:  method,
+				Attempt: attempt,
+				Wait:    wait,
+				Err:     err,
+			})
+		}
+
+		if sleep(ctx, wait) != nil {
+			return data, err
+		}
+	}
+}
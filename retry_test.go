@@ -0,0 +1,113 @@
+package telebot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	var calls int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/sendMessage") && n == 1:
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 0","parameters":{"retry_after":0}}`))
+		case strings.HasSuffix(r.URL.Path, "/sendMessage"):
+			w.Write([]byte(`{"ok":true,"result":{"message_id":1,"text":"text"}}`))
+		case strings.HasSuffix(r.URL.Path, "/deleteMessage"),
+			strings.HasSuffix(r.URL.Path, "/sendDice"),
+			strings.HasSuffix(r.URL.Path, "/getUpdates"):
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"ok":false,"error_code":500,"description":"Internal Server Error"}`))
+		case strings.HasSuffix(r.URL.Path, "/editMessageText"):
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(`<html><body><h1>502 Bad Gateway</h1></body></html>`))
+		case strings.HasSuffix(r.URL.Path, "/getChat"):
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 3600","parameters":{"retry_after":3600}}`))
+		}
+	}))
+	defer srv.Close()
+
+	var events []RetryEvent
+
+	b, err := NewBot(Settings{
+		URL:     srv.URL,
+		Client:  srv.Client(),
+		offline: true,
+		Retry: &RetryPolicy{
+			MaxAttempts: 3,
+			Backoff:     time.Millisecond,
+			OnRetry:     func(e RetryEvent) { events = append(events, e) },
+		},
+	})
+	require.NoError(t, err)
+
+	t.Run("flood", func(t *testing.T) {
+		msg, err := b.Send(&Chat{ID: 1}, "text")
+		require.NoError(t, err)
+		assert.Equal(t, "text", msg.Text)
+		assert.Equal(t, int32(2), calls)
+		require.Len(t, events, 1)
+		assert.Equal(t, "sendMessage", events[0].Method)
+		assert.Equal(t, 1, events[0].Attempt)
+	})
+
+	t.Run("internal", func(t *testing.T) {
+		calls, events = 0, nil
+
+		err := b.Delete(&Message{ID: 1, Chat: &Chat{ID: 1}})
+		assert.Equal(t, ErrInternal, err)
+		assert.Equal(t, int32(3), calls)
+		require.Len(t, events, 2)
+		assert.Equal(t, time.Millisecond, events[0].Wait)
+		assert.Equal(t, 2*time.Millisecond, events[1].Wait)
+	})
+
+	t.Run("gateway", func(t *testing.T) {
+		calls, events = 0, nil
+
+		_, err := b.Edit(&Message{ID: 1, Chat: &Chat{ID: 1}}, "text")
+		assert.Equal(t, 502, errorCode(err))
+		assert.True(t, IsRetryable(err))
+		assert.Equal(t, int32(3), calls)
+		assert.Len(t, events, 2)
+	})
+
+	t.Run("not idempotent", func(t *testing.T) {
+		calls, events = 0, nil
+
+		_, err := b.Send(&Chat{ID: 1}, Cube)
+		assert.Equal(t, ErrInternal, err)
+		assert.Equal(t, int32(1), calls)
+		assert.Empty(t, events)
+	})
+
+	t.Run("get updates", func(t *testing.T) {
+		calls, events = 0, nil
+
+		_, err := b.getUpdates(0, 0, 0, nil)
+		assert.Equal(t, ErrInternal, err)
+		assert.Equal(t, int32(1), calls)
+		assert.Empty(t, events)
+	})
+
+	t.Run("too long to wait", func(t *testing.T) {
+		calls, events = 0, nil
+
+		_, err := b.ChatByID("1")
+		assert.Error(t, err)
+		assert.Equal(t, int32(1), calls)
+		assert.Empty(t, events)
+	})
+}
@@ -0,0 +1,124 @@
+package telebot
+
+import (
+	"regexp"
+	"sync"
+)
+
+// textRoute is a handler of the texts matching
+// either the regexp or the predicate.
+type textRoute struct {
+	rx      *regexp.Regexp
+	match   func(Context) bool
+	handler HandlerFunc
+}
+
+type textRoutes struct {
+	mu   sync.RWMutex
+	list []textRoute
+}
+
+// textMatch holds the submatches of the regexp route.
+type textMatch struct {
+	groups []string
+	names  []string
+}
+
+const textMatchKey = "telebot:match"
+
+// HandleRegexp sets the handler for the texts matching the regexp.
+// The submatches are available to the handler with Matches and
+// NamedMatch.
+//
+// Text routes, both regexp and predicate ones, are checked in the
+// order they are registered, after the commands and the exact texts
+// set with Handle, but before OnText. The first matching route wins.
+//
+// Example:
+//
+//		b.HandleRegexp(regexp.MustCompile(`^order #(?P<id>\d+)$`), func(c tb.Context) error {
+//			return c.Send("Order " + tb.NamedMatch(c, "id"))
+//		})
+//
+func (b *Bot) HandleRegexp(rx *regexp.Regexp, handler interface{}, m ...MiddlewareFunc) {
+	b.addTextRoute(textRoute{rx: rx}, handler, m)
+}
+
+// HandleMatch sets the handler for the texts, which the predicate
+// is true for. The predicate is called while the update is being
+// processed, so it must be quick. See HandleRegexp for the order.
+//
+// Example:
+//
+//		b.HandleMatch(func(c tb.Context) bool {
+//			return strings.Contains(strings.ToLower(c.Text()), "help")
+//		}, onHelp)
+//
+func (b *Bot) HandleMatch(match func(Context) bool, handler interface{}, m ...MiddlewareFunc) {
+	b.addTextRoute(textRoute{match: match}, handler, m)
+}
+
+func (b *Bot) addTextRoute(route textRoute, handler interface{}, m []MiddlewareFunc) {
+	if route.rx == nil && route.match == nil {
+		panic("telebot: text route has no regexp or predicate")
+	}
+	route.handler = applyMiddleware(wrapHandler(handler), m...)
+
+	b.routes.mu.Lock()
+	b.routes.list = append(b.routes.list, route)
+	b.routes.mu.Unlock()
+}
+
+// routeText runs the first text route matching the message.
+func (b *Bot) routeText(c Context) bool {
+	b.routes.mu.RLock()
+	routes := b.routes.list
+	b.routes.mu.RUnlock()
+
+	text := c.Message().Text
+	for _, route := range routes {
+		if route.rx != nil {
+			groups := route.rx.FindStringSubmatch(text)
+			if groups == nil {
+				continue
+			}
+			c.Set(textMatchKey, &textMatch{
+				groups: groups,
+				names:  route.rx.SubexpNames(),
+			})
+		} else if !route.match(c) {
+			continue
+		}
+
+		b.runHandler(route.handler, c)
+		return true
+	}
+
+	return false
+}
+
+// Matches returns the submatches of the regexp route being handled:
+// the whole match followed by the groups. It returns nil for other
+// handlers.
+func Matches(c Context) []string {
+	if m, ok := c.Get(textMatchKey).(*textMatch); ok {
+		return m.groups
+	}
+	return nil
+}
+
+// NamedMatch returns the submatch of the named group
+// of the regexp route being handled, or empty string.
+func NamedMatch(c Context, name string) string {
+	m, ok := c.Get(textMatchKey).(*textMatch)
+	if !ok {
+		return ""
+	}
+
+	for i, n := range m.names {
+		if n == name && i < len(m.groups) {
+			return m.groups[i]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,46 @@
+package telebot
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBotTextRoutes(t *testing.T) {
+	b, err := NewBot(Settings{Synchronous: true, offline: true})
+	require.NoError(t, err)
+
+	var got []string
+	handle := func(name string) HandlerFunc {
+		return func(c Context) error {
+			assert.Nil(t, Matches(c))
+			got = append(got, name)
+			return nil
+		}
+	}
+
+	b.Handle("order #0", handle("exact"))
+	b.HandleRegexp(regexp.MustCompile(`^order #(?P<id>\d+)$`), func(c Context) error {
+		assert.Equal(t, c.Text(), Matches(c)[0])
+		assert.Equal(t, Matches(c)[1], NamedMatch(c, "id"))
+		assert.Empty(t, NamedMatch(c, "missing"))
+		got = append(got, "order "+NamedMatch(c, "id"))
+		return nil
+	})
+	b.HandleMatch(func(c Context) bool {
+		return strings.Contains(c.Text(), "help")
+	}, handle("help"))
+	b.HandleRegexp(regexp.MustCompile(`help me`), handle("unreachable"))
+	b.Handle(OnText, handle("text"))
+
+	assert.Panics(t, func() { b.HandleRegexp(nil, handle("nil")) })
+
+	for _, text := range []string{"order #0", "order #42", "please help me", "order #x"} {
+		b.ProcessUpdate(Update{Message: &Message{Text: text, Chat: &Chat{ID: 1}}})
+	}
+
+	assert.Equal(t, []string{"exact", "order 42", "help", "text"}, got)
+}
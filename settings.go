@@ -73,6 +73,17 @@ type Settings struct {
 	// It makes ProcessUpdate return after the handler is finished.
 	Synchronous bool
 
+	// Workers limits the number of handlers running in parallel
+	// by a fixed-size pool of goroutines. By default, every handler
+	// is run in its own goroutine. Ignored if Synchronous is set.
+	Workers int
+
+	// Ordering makes handlers of updates from the same chat (or user)
+	// run one after another, in the order updates were received, while
+	// the different chats are still handled in parallel.
+	// Requires Workers, defaults them to the number of CPUs.
+	Ordering Ordering
+
 	// Verbose forces bot to log all upcoming requests.
 	// Use for debugging purposes only.
 	Verbose bool
@@ -86,9 +97,34 @@ type Settings struct {
 	// on any panics recovered from endpoint handlers.
 	Reporter func(error)
 
+	// OnError is a callback function that will get called on errors
+	// returned from endpoint handlers and middleware. The context of
+	// the failed handler is passed along, so the originating update
+	// is available via c.Update(). Errors are passed as-is, thus
+	// APIError and FloodError types could be checked.
+	OnError func(err error, c Context)
+
 	// HTTP Client used to make requests to telegram api
 	Client *http.Client
 
+	// Retry enables automatic retries of failed requests.
+	// By default, requests are not retried.
+	Retry *RetryPolicy
+
+	// Limiter throttles outgoing requests to avoid hitting the
+	// Telegram limits. Use NewRateLimiter for the default limits.
+	// By default, requests are not throttled.
+	Limiter Limiter
+
+	// Storage keeps the session data available to the handlers
+	// with Context.Session. Use NewFileStorage to persist it.
+	// Default: NewMemoryStorage(10000)
+	Storage Storage
+
+	// SyncCommands makes Start set the bot's command list
+	// to the commands registered with Bot.Command.
+	SyncCommands bool
+
 	// Passed template engine, that will be used for all executable content.
 	TemplateEngine Template
 
@@ -0,0 +1,178 @@
+package telebot
+
+import (
+	"container/list"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Storage is a key-value storage for the session data.
+// Implementations must be safe for concurrent use.
+type Storage interface {
+	// Get returns the value by the key. If the key
+	// doesn't exist or is expired, ok is false.
+	Get(key string) (value []byte, ok bool, err error)
+
+	// Set sets the value by the key. The value expires
+	// after ttl, zero ttl means it never expires.
+	Set(key string, value []byte, ttl time.Duration) error
+
+	// Delete removes the value by the key.
+	Delete(key string) error
+}
+
+// Session is a view of the storage scoped by
+// the chat and the user of the update.
+// Values are encoded with encoding/json.
+//
+// Example:
+//
+//		b.Handle("/count", func(c tb.Context) error {
+//			var n int
+//			if _, err := c.Session().Get("count", &n); err != nil {
+//				return err
+//			}
+//			n++
+//			if err := c.Session().Set("count", n, 0); err != nil {
+//				return err
+//			}
+//			return c.Send(strconv.Itoa(n))
+//		})
+//
+type Session struct {
+	storage Storage
+	prefix  string
+}
+
+// NewSession returns the session of the user in the chat.
+// Zero chat or user means the session isn't bound to it.
+func NewSession(storage Storage, chat int64, user int) Session {
+	prefix := strconv.FormatInt(chat, 10) + ":" + strconv.Itoa(user) + ":"
+	return Session{storage: storage, prefix: prefix}
+}
+
+// Get decodes the value by the key into v.
+// If the key doesn't exist, ok is false and v is left untouched.
+func (s Session) Get(key string, v interface{}) (ok bool, err error) {
+	data, ok, err := s.storage.Get(s.prefix + key)
+	if err != nil || !ok {
+		return false, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, wrapError(err)
+	}
+	return true, nil
+}
+
+// Set encodes v and sets it by the key. See Storage.Set.
+func (s Session) Set(key string, v interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return wrapError(err)
+	}
+	return s.storage.Set(s.prefix+key, data, ttl)
+}
+
+// Delete removes the value by the key.
+func (s Session) Delete(key string) error {
+	return s.storage.Delete(s.prefix + key)
+}
+
+// MemoryStorage is an in-memory Storage, which evicts the
+// least recently used values when the size limit is reached.
+type MemoryStorage struct {
+	size int
+
+	mu    sync.Mutex
+	order *list.List
+	items map[string]*list.Element
+}
+
+type memoryItem struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+func (it *memoryItem) expired(now time.Time) bool {
+	return !it.expires.IsZero() && now.After(it.expires)
+}
+
+// NewMemoryStorage creates a MemoryStorage keeping at
+// most size values. Zero size means there is no limit.
+func NewMemoryStorage(size int) *MemoryStorage {
+	return &MemoryStorage{
+		size:  size,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// Get implements Storage interface.
+func (s *MemoryStorage) Get(key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	it := el.Value.(*memoryItem)
+	if it.expired(time.Now()) {
+		s.remove(el)
+		return nil, false, nil
+	}
+
+	s.order.MoveToFront(el)
+	return it.value, true, nil
+}
+
+// Set implements Storage interface.
+func (s *MemoryStorage) Set(key string, value []byte, ttl time.Duration) error {
+	it := &memoryItem{key: key, value: value}
+	if ttl > 0 {
+		it.expires = time.Now().Add(ttl)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.items[key]; ok {
+		el.Value = it
+		s.order.MoveToFront(el)
+		return nil
+	}
+
+	s.items[key] = s.order.PushFront(it)
+	if s.size > 0 && s.order.Len() > s.size {
+		s.remove(s.order.Back())
+	}
+	return nil
+}
+
+// Delete implements Storage interface.
+func (s *MemoryStorage) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.items[key]; ok {
+		s.remove(el)
+	}
+	return nil
+}
+
+// Len returns the number of stored values, including
+// the expired ones, which haven't been removed yet.
+func (s *MemoryStorage) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+func (s *MemoryStorage) remove(el *list.Element) {
+	s.order.Remove(el)
+	delete(s.items, el.Value.(*memoryItem).key)
+}
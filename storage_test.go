@@ -0,0 +1,186 @@
+package telebot
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testStorage(t *testing.T, s Storage) {
+	_, ok, err := s.Get("a")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, s.Set("a", []byte("1"), 0))
+	require.NoError(t, s.Set("b", []byte("2"), 10*time.Millisecond))
+
+	v, ok, err := s.Get("a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), v)
+
+	_, ok, _ = s.Get("b")
+	assert.True(t, ok)
+	time.Sleep(20 * time.Millisecond)
+	_, ok, _ = s.Get("b")
+	assert.False(t, ok)
+
+	require.NoError(t, s.Delete("a"))
+	require.NoError(t, s.Delete("a"))
+	_, ok, _ = s.Get("a")
+	assert.False(t, ok)
+}
+
+func TestMemoryStorage(t *testing.T) {
+	testStorage(t, NewMemoryStorage(0))
+
+	s := NewMemoryStorage(2)
+	s.Set("a", []byte("1"), 0)
+	s.Set("b", []byte("2"), 0)
+	s.Get("a")
+	s.Set("c", []byte("3"), 0)
+
+	assert.Equal(t, 2, s.Len())
+	_, ok, _ := s.Get("a")
+	assert.True(t, ok)
+	_, ok, _ = s.Get("b")
+	assert.False(t, ok, "least recently used value must be evicted")
+}
+
+func TestFileStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telebot")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "storage.log")
+
+	s, err := NewFileStorage(path, 0)
+	require.NoError(t, err)
+	testStorage(t, s)
+
+	require.NoError(t, s.Set("a", []byte("1"), 0))
+	require.NoError(t, s.Set("a", []byte("2"), 0))
+	require.NoError(t, s.Set("b", []byte("3"), 0))
+	require.NoError(t, s.Delete("b"))
+	require.NoError(t, s.Close())
+
+	// broken last line is left after a crash
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	require.NoError(t, err)
+	f.WriteString(`{"key":"c","val`)
+	f.Close()
+
+	s, err = NewFileStorage(path, 0)
+	require.NoError(t, err)
+
+	v, ok, err := s.Get("a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("2"), v)
+	_, ok, _ = s.Get("b")
+	assert.False(t, ok)
+
+	t.Run("compaction", func(t *testing.T) {
+		for i := 0; i < 2*minCompaction; i++ {
+			require.NoError(t, s.Set("key", []byte(strconv.Itoa(i)), 0))
+		}
+		require.NoError(t, s.Close())
+
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		defer f.Close()
+
+		lines := 0
+		for sc := bufio.NewScanner(f); sc.Scan(); {
+			lines++
+		}
+		assert.True(t, lines < minCompaction)
+
+		s, err := NewFileStorage(path, 0)
+		require.NoError(t, err)
+		defer s.Close()
+
+		v, ok, _ := s.Get("key")
+		assert.True(t, ok)
+		assert.Equal(t, []byte(strconv.Itoa(2*minCompaction-1)), v)
+		v, _, _ = s.Get("a")
+		assert.Equal(t, []byte("2"), v)
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		path := filepath.Join(dir, "limit.log")
+
+		s, err := NewFileStorage(path, 2)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Set("a", []byte("1"), 0))
+		require.NoError(t, s.Set("b", []byte("2"), 0))
+		s.Get("a")
+		require.NoError(t, s.Set("c", []byte("3"), 0))
+		assert.Equal(t, 2, s.Len())
+		require.NoError(t, s.Close())
+
+		// the eviction is persisted
+		s, err = NewFileStorage(path, 0)
+		require.NoError(t, err)
+		defer s.Close()
+
+		_, ok, _ := s.Get("b")
+		assert.False(t, ok)
+		_, ok, _ = s.Get("a")
+		assert.True(t, ok)
+		_, ok, _ = s.Get("c")
+		assert.True(t, ok)
+	})
+
+	t.Run("sweep", func(t *testing.T) {
+		s, err := NewFileStorage(filepath.Join(dir, "sweep.log"), 0)
+		require.NoError(t, err)
+		defer s.Close()
+
+		for i := 0; i < minCompaction-1; i++ {
+			require.NoError(t, s.Set(strconv.Itoa(i), []byte("v"), 200*time.Millisecond))
+		}
+		assert.Equal(t, minCompaction-1, s.Len())
+
+		time.Sleep(250 * time.Millisecond)
+		require.NoError(t, s.Set("live", []byte("v"), 0))
+		assert.Equal(t, 1, s.Len())
+	})
+}
+
+func TestSession(t *testing.T) {
+	b, err := NewBot(Settings{offline: true})
+	require.NoError(t, err)
+
+	c := b.NewContext(Update{Message: &Message{Chat: &Chat{ID: 1}, Sender: &User{ID: 2}}})
+	other := b.NewContext(Update{Message: &Message{Chat: &Chat{ID: 1}, Sender: &User{ID: 3}}})
+
+	type data struct{ N int }
+	require.NoError(t, c.Session().Set("data", data{N: 1}, 0))
+
+	var got data
+	ok, err := c.Session().Get("data", &got)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 1, got.N)
+
+	ok, err = other.Session().Get("data", &got)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	v, ok, _ := b.Storage().Get("1:2:data")
+	assert.True(t, ok)
+	assert.JSONEq(t, `{"N":1}`, string(v))
+
+	require.NoError(t, c.Session().Delete("data"))
+	ok, _ = c.Session().Get("data", &got)
+	assert.False(t, ok)
+}
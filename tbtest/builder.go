@@ -0,0 +1,551 @@
+package tbtest
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf16"
+
+	tb "github.com/demget/telebot"
+)
+
+// DefaultUser is the sender of the built updates, unless set.
+var DefaultUser = tb.User{
+	ID:           1,
+	FirstName:    "User",
+	Username:     "user",
+	LanguageCode: "en",
+}
+
+var (
+	updateSeq  int64
+	messageSeq int64
+	querySeq   int64
+)
+
+func nextID(seq *int64) int {
+	return int(atomic.AddInt64(seq, 1))
+}
+
+// queryID makes up a query ID looking like the ones Telegram sends.
+func queryID() string {
+	return strconv.FormatInt(4000000000000000000+atomic.AddInt64(&querySeq, 1), 10)
+}
+
+// PrivateChat returns the private chat with the user.
+func PrivateChat(u tb.User) tb.Chat {
+	return tb.Chat{
+		ID:        int64(u.ID),
+		Type:      tb.ChatPrivate,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Username:  u.Username,
+	}
+}
+
+// Group returns a basic group chat.
+func Group(id int64, title string) tb.Chat {
+	return tb.Chat{ID: id, Type: tb.ChatGroup, Title: title}
+}
+
+// SuperGroup returns a supergroup chat.
+func SuperGroup(id int64, title string) tb.Chat {
+	return tb.Chat{ID: id, Type: tb.ChatSuperGroup, Title: title}
+}
+
+// Channel returns a channel chat.
+func Channel(id int64, title, username string) tb.Chat {
+	return tb.Chat{ID: id, Type: tb.ChatChannel, Title: title, Username: username}
+}
+
+// MessageBuilder builds a message and the update delivering it.
+// The message is sent by DefaultUser to the private chat with the
+// sender, unless set otherwise. Entities are detected in the text
+// and the caption the way Telegram does it.
+//
+// Example:
+//
+//		upd := tbtest.NewMessage("/start@test_bot promo").
+//			From(user).
+//			In(tbtest.SuperGroup(-1001, "Chat")).
+//			Update()
+//
+type MessageBuilder struct {
+	msg    tb.Message
+	chat   *tb.Chat
+	sender *tb.User
+}
+
+// NewMessage starts building a message with the text.
+func NewMessage(text string) *MessageBuilder {
+	return &MessageBuilder{msg: tb.Message{
+		ID:       nextID(&messageSeq),
+		Unixtime: time.Now().Unix(),
+		Text:     text,
+		Entities: Entities(text),
+	}}
+}
+
+// ID sets the message ID.
+func (b *MessageBuilder) ID(id int) *MessageBuilder {
+	b.msg.ID = id
+	return b
+}
+
+// From sets the sender.
+func (b *MessageBuilder) From(u tb.User) *MessageBuilder {
+	b.sender = &u
+	return b
+}
+
+// In sets the chat. Channel posts have no sender.
+func (b *MessageBuilder) In(chat tb.Chat) *MessageBuilder {
+	b.chat = &chat
+	return b
+}
+
+// At sets the date.
+func (b *MessageBuilder) At(t time.Time) *MessageBuilder {
+	b.msg.Unixtime = t.Unix()
+	return b
+}
+
+// ReplyTo makes the message a reply.
+func (b *MessageBuilder) ReplyTo(msg *tb.Message) *MessageBuilder {
+	b.msg.ReplyTo = msg
+	return b
+}
+
+// Forwarded makes the message forwarded from the user.
+func (b *MessageBuilder) Forwarded(from tb.User, at time.Time) *MessageBuilder {
+	b.msg.OriginalSender = &from
+	b.msg.OriginalUnixtime = int(at.Unix())
+	return b
+}
+
+// Caption sets the caption of the media and its entities.
+func (b *MessageBuilder) Caption(caption string) *MessageBuilder {
+	b.msg.Caption = caption
+	b.msg.CaptionEntities = Entities(caption)
+	return b
+}
+
+// Photo attaches the photo.
+func (b *MessageBuilder) Photo(file tb.File) *MessageBuilder {
+	b.msg.Photo = &tb.Photo{File: file, Width: 1280, Height: 720}
+	return b
+}
+
+// Document attaches the document.
+func (b *MessageBuilder) Document(file tb.File, name string) *MessageBuilder {
+	b.msg.Document = &tb.Document{File: file, FileName: name}
+	return b
+}
+
+// Voice attaches the voice note.
+func (b *MessageBuilder) Voice(file tb.File, duration int) *MessageBuilder {
+	b.msg.Voice = &tb.Voice{File: file, Duration: duration, MIME: "audio/ogg"}
+	return b
+}
+
+// Sticker attaches the sticker.
+func (b *MessageBuilder) Sticker(file tb.File, emoji string) *MessageBuilder {
+	b.msg.Sticker = &tb.Sticker{File: file, Width: 512, Height: 512, Emoji: emoji}
+	return b
+}
+
+// Location attaches the location.
+func (b *MessageBuilder) Location(lat, lng float32) *MessageBuilder {
+	b.msg.Location = &tb.Location{Lat: lat, Lng: lng}
+	return b
+}
+
+// Contact attaches the contact.
+func (b *MessageBuilder) Contact(c tb.Contact) *MessageBuilder {
+	b.msg.Contact = &c
+	return b
+}
+
+// Joined makes it a service message about the users joined.
+func (b *MessageBuilder) Joined(users ...tb.User) *MessageBuilder {
+	if len(users) > 0 {
+		b.msg.UserJoined = &users[0]
+	}
+	b.msg.UsersJoined = users
+	return b
+}
+
+// Left makes it a service message about the user left.
+func (b *MessageBuilder) Left(u tb.User) *MessageBuilder {
+	b.msg.UserLeft = &u
+	return b
+}
+
+// Pinned makes it a service message about the pinned message.
+func (b *MessageBuilder) Pinned(msg *tb.Message) *MessageBuilder {
+	b.msg.PinnedMessage = msg
+	return b
+}
+
+// Title makes it a service message about the new chat title.
+func (b *MessageBuilder) Title(title string) *MessageBuilder {
+	b.msg.NewGroupTitle = title
+	return b
+}
+
+// Created makes it a service message about the chat creation,
+// depending on the chat type.
+func (b *MessageBuilder) Created() *MessageBuilder {
+	switch b.getChat().Type {
+	case tb.ChatSuperGroup:
+		b.msg.SuperGroupCreated = true
+	case tb.ChatChannel:
+		b.msg.ChannelCreated = true
+	default:
+		b.msg.GroupCreated = true
+	}
+	return b
+}
+
+// MigratedTo makes it a service message about the group
+// upgraded to the supergroup.
+func (b *MessageBuilder) MigratedTo(id int64) *MessageBuilder {
+	b.msg.MigrateTo = id
+	return b
+}
+
+// MigratedFrom makes it a service message in the supergroup
+// about the group it has been upgraded from.
+func (b *MessageBuilder) MigratedFrom(id int64) *MessageBuilder {
+	b.msg.MigrateFrom = id
+	return b
+}
+
+// Album sets the media group the message belongs to.
+func (b *MessageBuilder) Album(id string) *MessageBuilder {
+	b.msg.AlbumID = id
+	return b
+}
+
+func (b *MessageBuilder) getChat() tb.Chat {
+	if b.chat != nil {
+		return *b.chat
+	}
+	return PrivateChat(b.getSender())
+}
+
+func (b *MessageBuilder) getSender() tb.User {
+	if b.sender != nil {
+		return *b.sender
+	}
+	return DefaultUser
+}
+
+// Message returns the built message.
+func (b *MessageBuilder) Message() *tb.Message {
+	msg := b.msg
+
+	chat := b.getChat()
+	msg.Chat = &chat
+
+	if chat.Type != tb.ChatChannel {
+		sender := b.getSender()
+		msg.Sender = &sender
+	}
+
+	return &msg
+}
+
+// Update returns the update delivering the message, or
+// the channel post, if the chat is a channel.
+func (b *MessageBuilder) Update() tb.Update {
+	upd := tb.Update{ID: nextID(&updateSeq)}
+
+	msg := b.Message()
+	if msg.Chat.Type == tb.ChatChannel {
+		upd.ChannelPost = msg
+	} else {
+		upd.Message = msg
+	}
+	return upd
+}
+
+// Edited returns the update delivering the edited message.
+func (b *MessageBuilder) Edited() tb.Update {
+	upd := tb.Update{ID: nextID(&updateSeq)}
+
+	msg := b.Message()
+	msg.LastEdit = time.Now().Unix()
+	if msg.LastEdit <= msg.Unixtime {
+		msg.LastEdit = msg.Unixtime + 1
+	}
+
+	if msg.Chat.Type == tb.ChatChannel {
+		upd.EditedChannelPost = msg
+	} else {
+		upd.EditedMessage = msg
+	}
+	return upd
+}
+
+// Album returns the updates of the messages sent as an album.
+// They share a generated media group ID, and the chat, sender
+// and date of the first one.
+func Album(messages ...*MessageBuilder) []tb.Update {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	var (
+		first   = messages[0]
+		id      = strconv.Itoa(12000000000000000 + nextID(&messageSeq))
+		updates = make([]tb.Update, len(messages))
+	)
+
+	for i, m := range messages {
+		m.chat, m.sender = first.chat, first.sender
+		m.msg.Unixtime = first.msg.Unixtime
+		m.msg.AlbumID = id
+		updates[i] = m.Update()
+	}
+	return updates
+}
+
+// CallbackBuilder builds a callback query update.
+type CallbackBuilder struct {
+	cb tb.Callback
+}
+
+// NewCallback starts building the callback of the button
+// attached to the message. The sender is DefaultUser.
+func NewCallback(msg *tb.Message) *CallbackBuilder {
+	sender := DefaultUser
+	return &CallbackBuilder{cb: tb.Callback{
+		ID:      queryID(),
+		Sender:  &sender,
+		Message: msg,
+	}}
+}
+
+// NewInlineCallback starts building the callback of the button
+// attached to the inline message. The sender is DefaultUser.
+func NewInlineCallback(inlineMessageID string) *CallbackBuilder {
+	sender := DefaultUser
+	return &CallbackBuilder{cb: tb.Callback{
+		ID:        queryID(),
+		Sender:    &sender,
+		MessageID: inlineMessageID,
+	}}
+}
+
+// From sets the sender.
+func (b *CallbackBuilder) From(u tb.User) *CallbackBuilder {
+	b.cb.Sender = &u
+	return b
+}
+
+// Data sets the raw callback data.
+func (b *CallbackBuilder) Data(data string) *CallbackBuilder {
+	b.cb.Data = data
+	return b
+}
+
+// Button sets the data the way the button is sent by the bot:
+// buttons with the unique name get "\f<unique>|<data>".
+func (b *CallbackBuilder) Button(btn *tb.InlineButton) *CallbackBuilder {
+	data := btn.Data
+	if btn.Unique != "" && !strings.HasPrefix(data, "\f") {
+		if data == "" {
+			data = "\f" + btn.Unique
+		} else {
+			data = "\f" + btn.Unique + "|" + data
+		}
+	}
+	b.cb.Data = data
+	return b
+}
+
+// Callback returns the built callback.
+func (b *CallbackBuilder) Callback() *tb.Callback {
+	cb := b.cb
+	return &cb
+}
+
+// Update returns the update delivering the callback.
+func (b *CallbackBuilder) Update() tb.Update {
+	return tb.Update{ID: nextID(&updateSeq), Callback: b.Callback()}
+}
+
+// QueryBuilder builds an inline query update.
+type QueryBuilder struct {
+	q tb.Query
+}
+
+// NewQuery starts building the inline query sent by DefaultUser.
+func NewQuery(text string) *QueryBuilder {
+	return &QueryBuilder{q: tb.Query{
+		ID:   queryID(),
+		From: DefaultUser,
+		Text: text,
+	}}
+}
+
+// From sets the sender.
+func (b *QueryBuilder) From(u tb.User) *QueryBuilder {
+	b.q.From = u
+	return b
+}
+
+// Offset sets the offset of the results to be returned.
+func (b *QueryBuilder) Offset(offset string) *QueryBuilder {
+	b.q.Offset = offset
+	return b
+}
+
+// Location sets the location of the sender.
+func (b *QueryBuilder) Location(lat, lng float32) *QueryBuilder {
+	b.q.Location = &tb.Location{Lat: lat, Lng: lng}
+	return b
+}
+
+// Update returns the update delivering the query.
+func (b *QueryBuilder) Update() tb.Update {
+	q := b.q
+	return tb.Update{ID: nextID(&updateSeq), Query: &q}
+}
+
+// ChosenResult returns the update delivering the inline result
+// chosen by DefaultUser. Inline message ID is only set if the
+// result has an inline keyboard, pass empty otherwise.
+func ChosenResult(resultID, query, inlineMessageID string) tb.Update {
+	return tb.Update{
+		ID: nextID(&updateSeq),
+		ChosenInlineResult: &tb.ChosenInlineResult{
+			From:      DefaultUser,
+			ResultID:  resultID,
+			Query:     query,
+			MessageID: inlineMessageID,
+		},
+	}
+}
+
+// PollUpdate returns the update delivering the new state of the poll.
+func PollUpdate(poll tb.Poll) tb.Update {
+	return tb.Update{ID: nextID(&updateSeq), Poll: &poll}
+}
+
+// PollAnswer returns the update delivering the answer
+// of DefaultUser in the non-anonymous poll.
+func PollAnswer(pollID string, options ...int) tb.Update {
+	if options == nil {
+		// retracted vote
+		options = []int{}
+	}
+	return tb.Update{
+		ID: nextID(&updateSeq),
+		PollAnswer: &tb.PollAnswer{
+			PollID:  pollID,
+			User:    DefaultUser,
+			Options: options,
+		},
+	}
+}
+
+// ShippingQuery returns the update delivering
+// the shipping query of DefaultUser.
+func ShippingQuery(payload string, address tb.ShippingAddress) tb.Update {
+	sender := DefaultUser
+	return tb.Update{
+		ID: nextID(&updateSeq),
+		ShippingQuery: &tb.ShippingQuery{
+			Sender:  &sender,
+			ID:      queryID(),
+			Payload: payload,
+			Address: address,
+		},
+	}
+}
+
+// PreCheckoutQuery returns the update delivering
+// the pre-checkout query of DefaultUser.
+func PreCheckoutQuery(payload, currency string, total int) tb.Update {
+	sender := DefaultUser
+	return tb.Update{
+		ID: nextID(&updateSeq),
+		PreCheckoutQuery: &tb.PreCheckoutQuery{
+			Sender:   &sender,
+			ID:       queryID(),
+			Currency: currency,
+			Payload:  payload,
+			Total:    total,
+		},
+	}
+}
+
+// entityRules are the entities Telegram detects in plain text,
+// in the order of priority: overlapping ones are dropped.
+var entityRules = []struct {
+	typ tb.EntityType
+	rx  *regexp.Regexp
+}{
+	{tb.EntityURL, regexp.MustCompile(`(?:^|[^\w/])(https?://[^\s]*[^\s.,:;!?'")\]])`)},
+	{tb.EntityEmail, regexp.MustCompile(`(?:^|[^\w.+-])([\w.+-]+@[\w-]+(?:\.[\w-]+)*\.[a-zA-Z]{2,})`)},
+	{tb.EntityCommand, regexp.MustCompile(`(?:^|\s)(/[a-zA-Z0-9_]{1,64}(?:@[a-zA-Z0-9_]{3,32})?)`)},
+	{tb.EntityMention, regexp.MustCompile(`(?:^|[^\w@])(@[a-zA-Z0-9_]{5,32})`)},
+	{tb.EntityHashtag, regexp.MustCompile(`(?:^|[^\w#])(#[\p{L}\p{N}_]*[\p{L}_][\p{L}\p{N}_]*)`)},
+	{tb.EntityCashtag, regexp.MustCompile(`(?:^|[^\w$])(\$[A-Z]{3,8})\b`)},
+}
+
+// Entities returns the entities Telegram detects in the plain
+// text: commands, mentions, hashtags, cashtags, URLs and emails.
+// Offsets and lengths are measured in UTF-16 code units.
+func Entities(text string) []tb.MessageEntity {
+	type span struct {
+		typ        tb.EntityType
+		start, end int
+	}
+
+	var spans []span
+	for _, rule := range entityRules {
+		for _, loc := range rule.rx.FindAllStringSubmatchIndex(text, -1) {
+			s := span{typ: rule.typ, start: loc[2], end: loc[3]}
+
+			overlaps := false
+			for _, other := range spans {
+				if s.start < other.end && other.start < s.end {
+					overlaps = true
+					break
+				}
+			}
+			if !overlaps {
+				spans = append(spans, s)
+			}
+		}
+	}
+
+	if spans == nil {
+		return nil
+	}
+
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i].start < spans[j].start
+	})
+
+	entities := make([]tb.MessageEntity, len(spans))
+	for i, s := range spans {
+		offset := utf16Len(text[:s.start])
+		entities[i] = tb.MessageEntity{
+			Type:   s.typ,
+			Offset: offset,
+			Length: utf16Len(text[:s.end]) - offset,
+		}
+	}
+	return entities
+}
+
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
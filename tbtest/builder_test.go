@@ -0,0 +1,106 @@
+package tbtest
+
+import (
+	"testing"
+
+	tb "github.com/demget/telebot"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntities(t *testing.T) {
+	entity := func(typ tb.EntityType, offset, length int) tb.MessageEntity {
+		return tb.MessageEntity{Type: typ, Offset: offset, Length: length}
+	}
+
+	tests := []struct {
+		text string
+		want []tb.MessageEntity
+	}{
+		{"hello", nil},
+		{"/start", []tb.MessageEntity{entity(tb.EntityCommand, 0, 6)}},
+		{"/start@test_bot promo", []tb.MessageEntity{entity(tb.EntityCommand, 0, 15)}},
+		{"/a /b", []tb.MessageEntity{entity(tb.EntityCommand, 0, 2), entity(tb.EntityCommand, 3, 2)}},
+		{"a/b", nil},
+		{"hi @someone!", []tb.MessageEntity{entity(tb.EntityMention, 3, 8)}},
+		{"mail me@example.com", []tb.MessageEntity{entity(tb.EntityEmail, 5, 14)}},
+		{"see https://t.me/x.", []tb.MessageEntity{entity(tb.EntityURL, 4, 14)}},
+		{"#go $USD", []tb.MessageEntity{entity(tb.EntityHashtag, 0, 3), entity(tb.EntityCashtag, 4, 4)}},
+		// emoji takes two UTF-16 code units
+		{"👋 /help", []tb.MessageEntity{entity(tb.EntityCommand, 3, 5)}},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Entities(tt.text), tt.text)
+	}
+}
+
+func TestBuilders(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	b, err := srv.NewBot(tb.Settings{Synchronous: true})
+	require.NoError(t, err)
+
+	var got []string
+	handle := func(name string) tb.HandlerFunc {
+		return func(c tb.Context) error {
+			got = append(got, name)
+			return nil
+		}
+	}
+
+	btn := (&tb.ReplyMarkup{}).Data("Like", "like", "42")
+
+	b.Handle("/start", func(c tb.Context) error {
+		assert.Equal(t, "promo", c.Message().Payload)
+		got = append(got, "start")
+		return nil
+	})
+	b.Handle(&btn, func(c tb.Context) error {
+		assert.Equal(t, "42", c.Data())
+		got = append(got, "like")
+		return nil
+	})
+	b.Handle(tb.OnChannelPost, handle("post"))
+	b.Handle(tb.OnEdited, handle("edited"))
+	b.Handle(tb.OnUserJoined, handle("joined"))
+	b.Handle(tb.OnPinned, handle("pinned"))
+	b.Handle(tb.OnPhoto, handle("photo"))
+	b.Handle(tb.OnQuery, handle("query"))
+	b.Handle(tb.OnPollAnswer, handle("answer"))
+
+	group := SuperGroup(-1001, "Chat")
+	msg := NewMessage("/start@test_bot promo").In(group).Message()
+	assert.Equal(t, DefaultUser.ID, msg.Sender.ID)
+	assert.Equal(t, group.ID, msg.Chat.ID)
+
+	b.ProcessUpdate(NewMessage("/start@test_bot promo").In(group).Update())
+	b.ProcessUpdate(NewCallback(msg).Button(btn.Inline()).Update())
+	b.ProcessUpdate(NewMessage("news").In(Channel(-1002, "News", "news")).Update())
+	b.ProcessUpdate(NewMessage("fixed").Edited())
+	b.ProcessUpdate(NewMessage("").In(group).Joined(tb.User{ID: 2}).Update())
+	b.ProcessUpdate(NewMessage("").In(group).Pinned(msg).Update())
+	b.ProcessUpdate(NewQuery("cats").Update())
+	b.ProcessUpdate(PollAnswer("poll", 1))
+
+	album := Album(
+		NewMessage("").Photo(tb.File{FileID: "a"}).In(group),
+		NewMessage("").Photo(tb.File{FileID: "b"}),
+	)
+	require.Len(t, album, 2)
+	assert.NotEmpty(t, album[0].Message.AlbumID)
+	assert.Equal(t, album[0].Message.AlbumID, album[1].Message.AlbumID)
+	assert.Equal(t, group.ID, album[1].Message.Chat.ID)
+	for _, upd := range album {
+		b.ProcessUpdate(upd)
+	}
+
+	post := NewMessage("news").In(Channel(-1002, "News", "news")).Message()
+	assert.Nil(t, post.Sender)
+
+	assert.Equal(t, []string{
+		"start", "like", "post", "edited", "joined",
+		"pinned", "query", "answer", "photo", "photo",
+	}, got)
+}
@@ -0,0 +1,407 @@
+package tbtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	tb "github.com/demget/telebot"
+)
+
+var updateGolden = flag.Bool("tbtest.update", false, "rewrite the golden transcripts")
+
+// Harness simulates a user chatting with the bot. Every action of
+// the user is processed synchronously, and the calls the bot makes
+// in response are written to a readable transcript:
+//
+//		user: sends "/start"
+//		bot: sendMessage reply_markup=[Refresh] text="Hi!"
+//		user: taps "Refresh"
+//		bot: answerCallbackQuery
+//		bot: editMessageText message_id=2 reply_markup=[Refresh] text="Refreshed"
+//
+// The transcript can be compared against a golden file.
+//
+// Example:
+//
+//		h := tbtest.NewHarness(t, tb.Settings{})
+//		defer h.Close()
+//
+//		h.Bot.Handle("/start", onStart)
+//
+//		h.Send("/start")
+//		h.Tap("Refresh")
+//		h.Golden("testdata/start.golden")
+//
+// Run the tests with -tbtest.update flag to rewrite the golden files.
+type Harness struct {
+	Server *Server
+	Bot    *tb.Bot
+
+	// User is the one sending the updates.
+	User tb.User
+
+	// Chat is the private chat with the user.
+	Chat tb.Chat
+
+	t testing.TB
+
+	mu       sync.Mutex
+	lines    []string
+	errs     []string
+	seen     int
+	messages []*tb.Message
+}
+
+// NewHarness starts a fake server and creates a bot connected to it
+// with the settings. The bot is always synchronous and has no workers,
+// so the calls are made by the time an action returns.
+func NewHarness(t testing.TB, pref tb.Settings) *Harness {
+	t.Helper()
+
+	h := &Harness{
+		Server: NewServer(),
+		User:   DefaultUser,
+		Chat:   PrivateChat(DefaultUser),
+		t:      t,
+	}
+
+	onError := pref.OnError
+	pref.OnError = func(err error, c tb.Context) {
+		h.mu.Lock()
+		h.errs = append(h.errs, "error: "+err.Error())
+		h.mu.Unlock()
+
+		if onError != nil {
+			onError(err, c)
+		}
+	}
+	pref.Synchronous = true
+	pref.Workers = 0
+	pref.Ordering = tb.OrderNone
+
+	b, err := h.Server.NewBot(pref)
+	if err != nil {
+		h.Server.Close()
+		t.Fatal(err)
+	}
+	h.Bot = b
+	h.seen = len(h.Server.Calls())
+
+	return h
+}
+
+// Close stops the fake server.
+func (h *Harness) Close() {
+	h.Server.Close()
+}
+
+// Send simulates the user sending the text.
+func (h *Harness) Send(text string) {
+	h.t.Helper()
+	h.SendMessage(NewMessage(text), "sends "+strconv.Quote(text))
+}
+
+// SendPhoto simulates the user sending a photo with the caption.
+// The photo is stored on the server, so the bot could download it.
+func (h *Harness) SendPhoto(caption string) {
+	h.t.Helper()
+
+	file := h.Server.AddFile("photo.jpg", []byte("photo"))
+
+	action := "sends photo"
+	if caption != "" {
+		action += " " + strconv.Quote(caption)
+	}
+	h.SendMessage(NewMessage("").Photo(file).Caption(caption), action)
+}
+
+// SendMessage simulates the user sending the built message to the
+// chat of the harness. The action describes it in the transcript.
+func (h *Harness) SendMessage(m *MessageBuilder, action string) {
+	h.t.Helper()
+
+	msg := m.From(h.User).In(h.Chat).ID(h.Server.nextMessageID()).Message()
+	h.process(tb.Update{Message: msg}, action)
+}
+
+// Tap simulates the user tapping the button with the text. Inline
+// buttons of the latest bot message having such are looked up first,
+// then the reply keyboard buttons, tapping which sends the text.
+func (h *Harness) Tap(text string) {
+	h.t.Helper()
+
+	h.mu.Lock()
+	msg, button := h.findButton(text)
+	h.mu.Unlock()
+
+	if button == nil {
+		if h.hasReplyButton(text) {
+			h.SendMessage(NewMessage(text), "taps "+strconv.Quote(text))
+			return
+		}
+		h.t.Fatalf("tbtest: no button %q", text)
+		return
+	}
+
+	if button.Data == "" {
+		h.t.Fatalf("tbtest: button %q has no callback data", text)
+		return
+	}
+
+	cb := NewCallback(msg).From(h.User).Data(button.Data).Callback()
+	h.process(tb.Update{Callback: cb}, "taps "+strconv.Quote(text))
+}
+
+// findButton returns the latest bot message with the inline button.
+func (h *Harness) findButton(text string) (*tb.Message, *tb.InlineButton) {
+	for i := len(h.messages) - 1; i >= 0; i-- {
+		msg := h.messages[i]
+		for _, row := range msg.ReplyMarkup.InlineKeyboard {
+			for j := range row {
+				if row[j].Text == text {
+					cp := *msg
+					return &cp, &row[j]
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// hasReplyButton reports whether the latest reply keyboard has the button.
+func (h *Harness) hasReplyButton(text string) bool {
+	calls := h.Server.Calls()
+	for i := len(calls) - 1; i >= 0; i-- {
+		var markup tb.ReplyMarkup
+		if err := json.Unmarshal([]byte(calls[i].Params["reply_markup"]), &markup); err != nil {
+			continue
+		}
+		if markup.ReplyKeyboardRemove {
+			return false
+		}
+		if markup.ReplyKeyboard == nil {
+			continue
+		}
+		for _, row := range markup.ReplyKeyboard {
+			for _, btn := range row {
+				if btn.Text == text {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// Process processes the update, writing the action to the transcript.
+func (h *Harness) Process(upd tb.Update, action string) {
+	h.t.Helper()
+	h.process(upd, action)
+}
+
+func (h *Harness) process(upd tb.Update, action string) {
+	if upd.ID == 0 {
+		upd.ID = h.Server.nextUpdateID()
+	}
+
+	h.mu.Lock()
+	h.lines = append(h.lines, "user: "+action)
+	h.mu.Unlock()
+
+	h.Bot.ProcessUpdate(upd)
+	h.flush()
+}
+
+// flush writes the calls made since the last action to the transcript.
+func (h *Harness) flush() {
+	calls := h.Server.Calls()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// the server has been reset
+	if h.seen > len(calls) {
+		h.seen = 0
+	}
+
+	for _, c := range calls[h.seen:] {
+		h.lines = append(h.lines, "bot: "+h.format(c))
+
+		var msg tb.Message
+		if json.Unmarshal(c.Result, &msg) == nil && msg.ID != 0 {
+			h.remember(&msg)
+		}
+	}
+	h.seen = len(calls)
+
+	h.lines = append(h.lines, h.errs...)
+	h.errs = nil
+}
+
+// remember keeps the latest version of the bot message.
+func (h *Harness) remember(msg *tb.Message) {
+	for i, m := range h.messages {
+		if m.ID == msg.ID && m.Chat != nil && msg.Chat != nil && m.Chat.ID == msg.Chat.ID {
+			h.messages = append(h.messages[:i], h.messages[i+1:]...)
+			break
+		}
+	}
+	h.messages = append(h.messages, msg)
+}
+
+// format describes the call in a line: the method followed by
+// the sorted parameters. The chat of the harness, the ID of the
+// answered callback and empty parameters are omitted.
+func (h *Harness) format(c Call) string {
+	var keys []string
+	for k := range c.Params {
+		if k == "callback_query_id" || c.Params[k] == "" {
+			continue
+		}
+		if k == "chat_id" && c.Params[k] == strconv.FormatInt(h.Chat.ID, 10) {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	for k := range c.Files {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := []string{c.Method}
+	for _, k := range keys {
+		if f, ok := c.Files[k]; ok {
+			parts = append(parts, fmt.Sprintf("%s=<%d bytes>", k, len(f.Data)))
+			continue
+		}
+
+		v := c.Params[k]
+		if k == "reply_markup" {
+			v = formatMarkup(v)
+		} else if !isPlain(v) {
+			v = strconv.Quote(v)
+		}
+		parts = append(parts, k+"="+v)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// formatMarkup shows the keyboard as the button texts, with
+// the rows separated by semicolons: [A, B; C].
+func formatMarkup(v string) string {
+	var markup tb.ReplyMarkup
+	if err := json.Unmarshal([]byte(v), &markup); err != nil {
+		return strconv.Quote(v)
+	}
+
+	var rows []string
+	for _, row := range markup.InlineKeyboard {
+		var texts []string
+		for _, btn := range row {
+			texts = append(texts, btn.Text)
+		}
+		rows = append(rows, strings.Join(texts, ", "))
+	}
+	for _, row := range markup.ReplyKeyboard {
+		var texts []string
+		for _, btn := range row {
+			texts = append(texts, btn.Text)
+		}
+		rows = append(rows, strings.Join(texts, ", "))
+	}
+
+	if rows == nil {
+		return strconv.Quote(v)
+	}
+	return "[" + strings.Join(rows, "; ") + "]"
+}
+
+// isPlain reports whether the value is a number or a boolean,
+// which is written to the transcript as is.
+func isPlain(v string) bool {
+	if v == "true" || v == "false" {
+		return true
+	}
+	_, err := strconv.ParseFloat(v, 64)
+	return err == nil
+}
+
+// Transcript returns the transcript so far.
+func (h *Harness) Transcript() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var buf bytes.Buffer
+	for _, line := range h.lines {
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+// Golden compares the transcript with the golden file and fails
+// the test if they differ. With -tbtest.update flag, the file is
+// written instead.
+func (h *Harness) Golden(path string) {
+	h.t.Helper()
+
+	got := h.Transcript()
+
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			h.t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(got), 0644); err != nil {
+			h.t.Fatal(err)
+		}
+		return
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		h.t.Fatalf("tbtest: %v (run with -tbtest.update to create it)", err)
+		return
+	}
+
+	want := strings.Replace(string(data), "\r\n", "\n", -1)
+	if got != want {
+		h.t.Errorf("tbtest: transcript differs from %s:\n%s", path, diff(want, got))
+	}
+}
+
+// diff shows the lines from the first mismatch on.
+func diff(want, got string) string {
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+
+	i := 0
+	for i < len(wantLines) && i < len(gotLines) && wantLines[i] == gotLines[i] {
+		i++
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "line %d:\n", i+1)
+	for _, line := range wantLines[i:] {
+		if line != "" {
+			fmt.Fprintf(&buf, "- %s\n", line)
+		}
+	}
+	for _, line := range gotLines[i:] {
+		if line != "" {
+			fmt.Fprintf(&buf, "+ %s\n", line)
+		}
+	}
+	return buf.String()
+}
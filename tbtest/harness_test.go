@@ -0,0 +1,45 @@
+package tbtest
+
+import (
+	"errors"
+	"testing"
+
+	tb "github.com/demget/telebot"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHarness(t *testing.T) {
+	h := NewHarness(t, tb.Settings{})
+	defer h.Close()
+
+	var (
+		menu    = &tb.ReplyMarkup{}
+		refresh = menu.Data("Refresh", "refresh", "1")
+	)
+	menu.Inline(menu.Row(refresh))
+
+	h.Bot.Handle("/start", func(c tb.Context) error {
+		assert.Equal(t, "now", c.Message().Payload)
+		return c.Send("Hi!", menu)
+	})
+	h.Bot.Handle(&refresh, func(c tb.Context) error {
+		assert.Equal(t, "1", c.Data())
+		if err := c.Respond(); err != nil {
+			return err
+		}
+		return c.Edit("Refreshed", menu)
+	})
+	h.Bot.Handle(tb.OnPhoto, func(c tb.Context) error {
+		return c.Send(&tb.Photo{File: c.Message().Photo.File})
+	})
+	h.Bot.Handle("/fail", func(c tb.Context) error {
+		return errors.New("failed")
+	})
+
+	h.Send("/start now")
+	h.Tap("Refresh")
+	h.SendPhoto("cat")
+	h.Send("/fail")
+
+	h.Golden("testdata/harness.golden")
+}
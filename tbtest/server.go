@@ -0,0 +1,820 @@
+// Package tbtest provides a fake Telegram Bot API server, so the
+// bots built on telebot could be tested end to end with no network.
+//
+// Example:
+//
+//		srv := tbtest.NewServer()
+//		defer srv.Close()
+//
+//		b, _ := srv.NewBot(tb.Settings{Synchronous: true})
+//		b.Handle("/start", func(c tb.Context) error {
+//			return c.Send("Hello!")
+//		})
+//
+//		b.ProcessUpdate(srv.AddUpdate(tb.Update{Message: msg}))
+//		calls := srv.CallsTo("sendMessage")
+//
+package tbtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tb "github.com/demget/telebot"
+)
+
+// DefaultToken is the token the server accepts by default.
+const DefaultToken = "123456:TEST"
+
+// Call is a recorded Bot API request.
+type Call struct {
+	Method string
+
+	// Params holds the request parameters. The ones, which
+	// aren't strings, are kept as the JSON they're sent in.
+	Params map[string]string
+
+	// Files holds the files uploaded with multipart requests.
+	Files map[string]File
+
+	// Result is the JSON result of the call, if it has succeeded.
+	Result json.RawMessage
+}
+
+// File is an uploaded or stored file.
+type File struct {
+	Name string
+	Data []byte
+}
+
+// Failure is an error response of the server.
+type Failure struct {
+	Code        int
+	Description string
+
+	// (Optional) Sent as response parameters.
+	RetryAfter int
+	MigrateTo  int64
+}
+
+// Handler produces the result of a Bot API call. It returns
+// either the result, which is marshaled to JSON, or a failure.
+type Handler func(c Call) (result interface{}, fail *Failure)
+
+// Server is an in-process fake of the Telegram Bot API, built on
+// httptest.Server. It records the calls, serves the injected
+// updates with getUpdates, and replies to the common methods with
+// plausible results: send* methods return a message sent by the
+// bot, edit* methods apply the changes to the sent message and
+// return it, getFile returns the stored files, the rest of the
+// methods return true.
+//
+// getUpdates calls aren't recorded, as pollers make them endlessly.
+type Server struct {
+	*httptest.Server
+
+	// Token is the token the server accepts, others
+	// are rejected with 401. Default: DefaultToken
+	Token string
+
+	// Me is the bot returned by getMe.
+	Me tb.User
+
+	mu        sync.Mutex
+	calls     []Call
+	resets    int
+	updates   []tb.Update
+	updateID  int
+	messageID int
+	fileID    int
+	files     map[string]File
+	messages  map[string]*tb.Message
+	polls     map[string]*poll
+	commands  json.RawMessage
+	failures  map[string][]Failure
+	handlers  map[string]Handler
+	added     chan struct{}
+}
+
+// NewServer starts a new fake server. It should be closed when finished.
+func NewServer() *Server {
+	s := &Server{
+		Token: DefaultToken,
+		Me: tb.User{
+			ID:        123456,
+			IsBot:     true,
+			FirstName: "Test",
+			Username:  "test_bot",
+		},
+		files:    make(map[string]File),
+		messages: make(map[string]*tb.Message),
+		polls:    make(map[string]*poll),
+		failures: make(map[string][]Failure),
+		handlers: make(map[string]Handler),
+		added:    make(chan struct{}),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Settings returns the bot settings pointed to the server.
+func (s *Server) Settings() tb.Settings {
+	return tb.Settings{
+		URL:    s.URL,
+		Token:  s.Token,
+		Client: s.Client(),
+	}
+}
+
+// NewBot creates a bot with the settings pointed to the server.
+// URL, Token and Client are overridden, the rest is kept.
+func (s *Server) NewBot(pref tb.Settings) (*tb.Bot, error) {
+	pref.URL = s.URL
+	pref.Token = s.Token
+	pref.Client = s.Client()
+	return tb.NewBot(pref)
+}
+
+// Calls returns all the recorded calls in their order.
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+// CallsTo returns the recorded calls of the method.
+func (s *Server) CallsTo(method string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var calls []Call
+	for _, c := range s.calls {
+		if c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Reset forgets the recorded calls, pending updates and failures.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls = nil
+	s.resets++
+	s.updates = nil
+	s.failures = make(map[string][]Failure)
+}
+
+// AddUpdate queues the update for getUpdates and returns it.
+// The update ID is assigned if it's zero. The update is also
+// suitable for Bot.ProcessUpdate, when the bot isn't polling.
+func (s *Server) AddUpdate(upd tb.Update) tb.Update {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if upd.ID == 0 {
+		upd.ID = s.updateID + 1
+	}
+	if upd.ID > s.updateID {
+		s.updateID = upd.ID
+	}
+
+	s.updates = append(s.updates, upd)
+
+	close(s.added)
+	s.added = make(chan struct{})
+	return upd
+}
+
+// Pending returns the number of the updates, which
+// haven't been confirmed with getUpdates offset yet.
+func (s *Server) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.updates)
+}
+
+// AddFile stores the file, so it can be requested with getFile
+// and downloaded, and returns its description.
+func (s *Server) AddFile(name string, data []byte) tb.File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addFile(File{Name: name, Data: data})
+}
+
+// Fail makes the next call of the method fail. Several failures
+// are returned one by one. Use "*" as a method to fail any call,
+// except getUpdates.
+func (s *Server) Fail(method string, f Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[method] = append(s.failures[method], f)
+}
+
+// Flood makes the next call of the method
+// fail with 429 Too Many Requests.
+func (s *Server) Flood(method string, retryAfter int) {
+	s.Fail(method, Failure{
+		Code:        http.StatusTooManyRequests,
+		Description: "Too Many Requests: retry after " + strconv.Itoa(retryAfter),
+		RetryAfter:  retryAfter,
+	})
+}
+
+// Forbid makes the next call of the method fail
+// with 403, as if the bot was blocked by the user.
+func (s *Server) Forbid(method string) {
+	s.Fail(method, Failure{
+		Code:        http.StatusForbidden,
+		Description: "Forbidden: bot was blocked by the user",
+	})
+}
+
+func (s *Server) nextMessageID() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messageID++
+	return s.messageID
+}
+
+func (s *Server) nextUpdateID() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updateID++
+	return s.updateID
+}
+
+// Handle overrides the result of the method.
+func (s *Server) Handle(method string, h Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method] = h
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	if path := "/file/bot" + s.Token + "/"; strings.HasPrefix(r.URL.Path, path) {
+		s.serveFile(w, strings.TrimPrefix(r.URL.Path, path))
+		return
+	}
+
+	prefix := "/bot" + s.Token + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		if strings.HasPrefix(r.URL.Path, "/bot") {
+			writeFailure(w, &Failure{Code: http.StatusUnauthorized, Description: "Unauthorized"})
+		} else {
+			writeFailure(w, &Failure{Code: http.StatusNotFound, Description: "Not Found"})
+		}
+		return
+	}
+
+	call, err := parseCall(strings.TrimPrefix(r.URL.Path, prefix), r)
+	if err != nil {
+		writeFailure(w, &Failure{Code: http.StatusBadRequest, Description: "Bad Request: " + err.Error()})
+		return
+	}
+
+	if call.Method == "getUpdates" {
+		if fail := s.takeFailure(call.Method); fail != nil {
+			writeFailure(w, fail)
+			return
+		}
+		writeResult(w, s.getUpdates(r, call))
+		return
+	}
+
+	s.mu.Lock()
+	s.calls = append(s.calls, call)
+	index, resets := len(s.calls)-1, s.resets
+	handler := s.handlers[call.Method]
+	s.mu.Unlock()
+
+	var (
+		result interface{}
+		fail   = s.takeFailure(call.Method, "*")
+	)
+	switch {
+	case fail != nil:
+	case handler != nil:
+		result, fail = handler(call)
+	default:
+		result, fail = s.result(call)
+	}
+
+	if fail != nil {
+		writeFailure(w, fail)
+		return
+	}
+
+	data := writeResult(w, result)
+
+	s.mu.Lock()
+	if s.resets == resets {
+		s.calls[index].Result = data
+	}
+	s.mu.Unlock()
+}
+
+func (s *Server) takeFailure(keys ...string) *Failure {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		if fails := s.failures[key]; len(fails) > 0 {
+			s.failures[key] = fails[1:]
+			return &fails[0]
+		}
+	}
+	return nil
+}
+
+// getUpdates confirms the updates below the offset and returns the
+// rest, waiting for the new ones up to the timeout.
+func (s *Server) getUpdates(r *http.Request, c Call) []tb.Update {
+	offset, _ := strconv.Atoi(c.Params["offset"])
+	timeout, _ := strconv.Atoi(c.Params["timeout"])
+	limit, _ := strconv.Atoi(c.Params["limit"])
+	if limit <= 0 || limit > 100 {
+		limit = 100
+	}
+
+	deadline := time.After(time.Duration(timeout) * time.Second)
+	for {
+		s.mu.Lock()
+		pending := s.updates[:0]
+		for _, upd := range s.updates {
+			if upd.ID >= offset {
+				pending = append(pending, upd)
+			}
+		}
+		s.updates = pending
+
+		if len(pending) > 0 || timeout <= 0 {
+			if len(pending) > limit {
+				pending = pending[:limit]
+			}
+			updates := append([]tb.Update{}, pending...)
+			s.mu.Unlock()
+			return updates
+		}
+
+		added := s.added
+		s.mu.Unlock()
+
+		select {
+		case <-added:
+		case <-deadline:
+			return []tb.Update{}
+		case <-r.Context().Done():
+			return []tb.Update{}
+		}
+	}
+}
+
+func (s *Server) serveFile(w http.ResponseWriter, path string) {
+	s.mu.Lock()
+	file, ok := s.files[strings.TrimPrefix(path, "files/")]
+	s.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, nil)
+		return
+	}
+	w.Write(file.Data)
+}
+
+// result returns the default result of the call.
+func (s *Server) result(c Call) (interface{}, *Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch c.Method {
+	case "getMe":
+		return s.Me, nil
+	case "getChat":
+		return parseChat(c.Params["chat_id"]), nil
+	case "getWebhookInfo":
+		return map[string]interface{}{"url": "", "pending_update_count": 0}, nil
+	case "getMyCommands":
+		if s.commands == nil {
+			return []tb.Command{}, nil
+		}
+		return s.commands, nil
+	case "setMyCommands":
+		s.commands = json.RawMessage(c.Params["commands"])
+		return true, nil
+	case "getFile":
+		id := c.Params["file_id"]
+		file, ok := s.files[id]
+		if !ok {
+			return nil, &Failure{Code: http.StatusBadRequest, Description: "Bad Request: invalid file_id"}
+		}
+		return s.describe(id, file), nil
+	case "forwardMessage", "copyMessage":
+		return s.message(c), nil
+	case "sendMediaGroup":
+		return s.album(c), nil
+	case "sendPoll":
+		return s.sendPoll(c), nil
+	case "stopPoll":
+		return s.stopPoll(c), nil
+	case "exportChatInviteLink":
+		return "https://t.me/joinchat/" + strings.TrimPrefix(c.Params["chat_id"], "-"), nil
+	case "getChatMember":
+		id, _ := strconv.Atoi(c.Params["user_id"])
+		if id == s.Me.ID {
+			return tb.ChatMember{User: &s.Me, Role: tb.Administrator}, nil
+		}
+		return tb.ChatMember{User: &tb.User{ID: id}, Role: tb.Member}, nil
+	case "getChatAdministrators":
+		return []tb.ChatMember{{User: &s.Me, Role: tb.Administrator}}, nil
+	case "getChatMembersCount":
+		return 1, nil
+	case "getUserProfilePhotos":
+		return map[string]interface{}{"total_count": 0, "photos": []interface{}{}}, nil
+	case "getGameHighScores":
+		return []tb.GameHighScore{}, nil
+	case "getStickerSet":
+		return tb.StickerSet{Name: c.Params["name"], Title: c.Params["name"], Stickers: []tb.Sticker{}}, nil
+	case "uploadStickerFile":
+		return s.file(c, "png_sticker"), nil
+	}
+
+	switch {
+	case strings.HasPrefix(c.Method, "send") && c.Method != "sendChatAction":
+		return s.message(c), nil
+	case strings.HasPrefix(c.Method, "editMessage"),
+		c.Method == "stopMessageLiveLocation",
+		c.Method == "setGameScore":
+		if c.Params["inline_message_id"] != "" {
+			return true, nil
+		}
+		return s.edit(c), nil
+	case c.Method == "deleteMessage":
+		key := messageKey(c.Params["chat_id"], c.Params["message_id"])
+		delete(s.messages, key)
+		delete(s.polls, key)
+	}
+
+	return true, nil
+}
+
+// newMessage makes up the next message sent by the call.
+func (s *Server) newMessage(c Call) *tb.Message {
+	s.messageID++
+
+	return &tb.Message{
+		ID:       s.messageID,
+		Sender:   &s.Me,
+		Unixtime: time.Now().Unix(),
+		Chat:     parseChat(c.Params["chat_id"]),
+	}
+}
+
+// poll is a tb.Poll, which can be marshaled: the type of
+// the latter is marshaled as the keyboard button poll type.
+type poll struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Question  string          `json:"question"`
+	Options   []tb.PollOption `json:"options"`
+	Closed    bool            `json:"is_closed"`
+	Anonymous bool            `json:"is_anonymous"`
+}
+
+// pollMessage is a message with the poll.
+type pollMessage struct {
+	*tb.Message
+	Poll *poll `json:"poll"`
+}
+
+// sendPoll builds the message with the poll.
+func (s *Server) sendPoll(c Call) pollMessage {
+	msg := s.message(c)
+
+	p := &poll{
+		ID:        "poll" + strconv.Itoa(msg.ID),
+		Type:      c.Params["type"],
+		Question:  c.Params["question"],
+		Closed:    c.Params["is_closed"] == "true",
+		Anonymous: c.Params["is_anonymous"] != "false",
+	}
+
+	var options []string
+	json.Unmarshal([]byte(c.Params["options"]), &options)
+	for _, text := range options {
+		p.Options = append(p.Options, tb.PollOption{Text: text})
+	}
+
+	s.polls[messageKey(c.Params["chat_id"], strconv.Itoa(msg.ID))] = p
+
+	cp := *p
+	return pollMessage{Message: msg, Poll: &cp}
+}
+
+// message builds the message sent by the call.
+func (s *Server) message(c Call) *tb.Message {
+	msg := s.newMessage(c)
+	msg.Text = c.Params["text"]
+	msg.Caption = c.Params["caption"]
+
+	switch c.Method {
+	case "sendPhoto":
+		msg.Photo = &tb.Photo{File: s.file(c, "photo")}
+	case "sendAudio":
+		msg.Audio = &tb.Audio{File: s.file(c, "audio")}
+	case "sendDocument":
+		msg.Document = &tb.Document{File: s.file(c, "document")}
+	case "sendVideo":
+		msg.Video = &tb.Video{File: s.file(c, "video")}
+	case "sendAnimation":
+		msg.Animation = &tb.Animation{File: s.file(c, "animation")}
+	case "sendVoice":
+		msg.Voice = &tb.Voice{File: s.file(c, "voice")}
+	case "sendVideoNote":
+		msg.VideoNote = &tb.VideoNote{File: s.file(c, "video_note")}
+	case "sendSticker":
+		msg.Sticker = &tb.Sticker{File: s.file(c, "sticker")}
+	case "sendLocation":
+		lat, _ := strconv.ParseFloat(c.Params["latitude"], 32)
+		lng, _ := strconv.ParseFloat(c.Params["longitude"], 32)
+		msg.Location = &tb.Location{Lat: float32(lat), Lng: float32(lng)}
+	}
+
+	msg.ReplyMarkup = parseMarkup(c.Params["reply_markup"])
+	s.messages[messageKey(c.Params["chat_id"], strconv.Itoa(msg.ID))] = msg
+
+	cp := *msg
+	return &cp
+}
+
+// album builds the messages sent by the sendMediaGroup call.
+func (s *Server) album(c Call) []tb.Message {
+	var media []struct {
+		Type    string `json:"type"`
+		Media   string `json:"media"`
+		Caption string `json:"caption"`
+	}
+	json.Unmarshal([]byte(c.Params["media"]), &media)
+
+	albumID := "album" + strconv.Itoa(s.messageID+1)
+	msgs := make([]tb.Message, 0, len(media))
+
+	for _, m := range media {
+		msg := s.newMessage(c)
+		msg.AlbumID = albumID
+		msg.Caption = m.Caption
+
+		file := s.ref(c, m.Media)
+		switch m.Type {
+		case "photo":
+			msg.Photo = &tb.Photo{File: file}
+		case "video":
+			msg.Video = &tb.Video{File: file}
+		case "audio":
+			msg.Audio = &tb.Audio{File: file}
+		case "document":
+			msg.Document = &tb.Document{File: file}
+		}
+
+		s.messages[messageKey(c.Params["chat_id"], strconv.Itoa(msg.ID))] = msg
+		msgs = append(msgs, *msg)
+	}
+
+	return msgs
+}
+
+// stopPoll closes the poll of the sent message,
+// or makes up the poll if it hasn't been sent.
+func (s *Server) stopPoll(c Call) poll {
+	key := messageKey(c.Params["chat_id"], c.Params["message_id"])
+
+	p, ok := s.polls[key]
+	if !ok {
+		p = &poll{ID: "poll" + c.Params["message_id"], Type: string(tb.PollRegular)}
+		s.polls[key] = p
+	}
+
+	p.Closed = true
+	return *p
+}
+
+// edit applies the edit to the sent message, or makes
+// up the message if it hasn't been sent by the server.
+func (s *Server) edit(c Call) *tb.Message {
+	key := messageKey(c.Params["chat_id"], c.Params["message_id"])
+
+	msg, ok := s.messages[key]
+	if !ok {
+		id, _ := strconv.Atoi(c.Params["message_id"])
+		msg = &tb.Message{
+			ID:     id,
+			Sender: &s.Me,
+			Chat:   parseChat(c.Params["chat_id"]),
+		}
+		s.messages[key] = msg
+	}
+
+	switch c.Method {
+	case "editMessageText":
+		msg.Text = c.Params["text"]
+	case "editMessageCaption":
+		msg.Caption = c.Params["caption"]
+	}
+
+	msg.ReplyMarkup = parseMarkup(c.Params["reply_markup"])
+	msg.LastEdit = time.Now().Unix()
+
+	cp := *msg
+	return &cp
+}
+
+func messageKey(chatID, messageID string) string {
+	return chatID + ":" + messageID
+}
+
+// parseMarkup returns the inline keyboard of the reply markup.
+func parseMarkup(markup string) tb.InlineKeyboardMarkup {
+	var m tb.InlineKeyboardMarkup
+	if markup != "" {
+		json.Unmarshal([]byte(markup), &m)
+	}
+	return m
+}
+
+// file returns the file sent in the field: a newly uploaded one,
+// an already stored one, or an empty one referenced by the URL.
+func (s *Server) file(c Call, field string) tb.File {
+	if f, ok := c.Files[field]; ok {
+		return s.addFile(f)
+	}
+	return s.ref(c, c.Params[field])
+}
+
+// ref returns the file referenced by the media field
+// of an album, the ID or URL.
+func (s *Server) ref(c Call, ref string) tb.File {
+	if strings.HasPrefix(ref, "attach://") {
+		if f, ok := c.Files[strings.TrimPrefix(ref, "attach://")]; ok {
+			return s.addFile(f)
+		}
+	}
+
+	if f, ok := s.files[ref]; ok {
+		return s.describe(ref, f)
+	}
+	return s.addFile(File{Name: ref})
+}
+
+func (s *Server) addFile(f File) tb.File {
+	s.fileID++
+	id := "file" + strconv.Itoa(s.fileID)
+	s.files[id] = f
+	return s.describe(id, f)
+}
+
+func (s *Server) describe(id string, f File) tb.File {
+	return tb.File{
+		FileID:   id,
+		UniqueID: "unique-" + id,
+		FileSize: len(f.Data),
+		FilePath: "files/" + id,
+	}
+}
+
+// parseChat makes up the chat by its ID or @username.
+func parseChat(chatID string) *tb.Chat {
+	if strings.HasPrefix(chatID, "@") {
+		return &tb.Chat{
+			Type:     tb.ChatChannel,
+			Username: strings.TrimPrefix(chatID, "@"),
+		}
+	}
+
+	id, _ := strconv.ParseInt(chatID, 10, 64)
+
+	chat := &tb.Chat{ID: id, Type: tb.ChatPrivate}
+	if id < 0 {
+		chat.Type = tb.ChatGroup
+		if strings.HasPrefix(chatID, "-100") {
+			chat.Type = tb.ChatSuperGroup
+		}
+	}
+	return chat
+}
+
+// parseCall reads the parameters from a JSON, form
+// or multipart request, or from the query string.
+func parseCall(method string, r *http.Request) (Call, error) {
+	c := Call{
+		Method: method,
+		Params: make(map[string]string),
+		Files:  make(map[string]File),
+	}
+
+	for k, v := range r.URL.Query() {
+		c.Params[k] = v[0]
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	switch mediaType {
+	case "application/json":
+		var raw map[string]json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&raw); err != nil && err != io.EOF {
+			return c, err
+		}
+		for k, v := range raw {
+			var s string
+			if err := json.Unmarshal(v, &s); err == nil {
+				c.Params[k] = s
+			} else {
+				c.Params[k] = string(v)
+			}
+		}
+	case "application/x-www-form-urlencoded":
+		if err := r.ParseForm(); err != nil {
+			return c, err
+		}
+		for k, v := range r.PostForm {
+			c.Params[k] = v[0]
+		}
+	case "multipart/form-data":
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return c, err
+			}
+
+			data, err := ioutil.ReadAll(part)
+			if err != nil {
+				return c, err
+			}
+
+			// readers are uploaded with an empty file name
+			_, disp, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+			if name, ok := disp["filename"]; ok {
+				c.Files[part.FormName()] = File{Name: name, Data: data}
+			} else {
+				c.Params[part.FormName()] = string(data)
+			}
+		}
+	}
+
+	return c, nil
+}
+
+func writeResult(w http.ResponseWriter, result interface{}) json.RawMessage {
+	data, err := json.Marshal(result)
+	if err != nil {
+		writeFailure(w, &Failure{Code: http.StatusInternalServerError, Description: err.Error()})
+		return nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`{"ok":true,"result":`)
+	buf.Write(data)
+	buf.WriteString("}")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(buf.Bytes())
+	return data
+}
+
+func writeFailure(w http.ResponseWriter, f *Failure) {
+	resp := map[string]interface{}{
+		"ok":          false,
+		"error_code":  f.Code,
+		"description": f.Description,
+	}
+
+	params := make(map[string]interface{})
+	if f.RetryAfter > 0 {
+		params["retry_after"] = f.RetryAfter
+	}
+	if f.MigrateTo != 0 {
+		params["migrate_to_chat_id"] = f.MigrateTo
+	}
+	if len(params) > 0 {
+		resp["parameters"] = params
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(f.Code)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,182 @@
+package tbtest
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	tb "github.com/demget/telebot"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerSend(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	b, err := srv.NewBot(tb.Settings{Synchronous: true})
+	require.NoError(t, err)
+	assert.Equal(t, "test_bot", b.Me.Username)
+
+	chat := tb.ChatID(42)
+
+	msg, err := b.Send(chat, "hello")
+	require.NoError(t, err)
+	assert.Equal(t, 1, msg.ID)
+	assert.Equal(t, int64(42), msg.Chat.ID)
+	assert.Equal(t, "hello", msg.Text)
+
+	calls := srv.CallsTo("sendMessage")
+	require.Len(t, calls, 1)
+	assert.Equal(t, "42", calls[0].Params["chat_id"])
+	assert.Equal(t, "hello", calls[0].Params["text"])
+
+	edited, err := b.Edit(msg, "bye")
+	require.NoError(t, err)
+	assert.Equal(t, msg.ID, edited.ID)
+	assert.Equal(t, "bye", edited.Text)
+
+	photo := &tb.Photo{File: tb.FromReader(strings.NewReader("image")), Caption: "cat"}
+	msg, err = b.Send(chat, photo)
+	require.NoError(t, err)
+	require.NotNil(t, msg.Photo)
+	assert.Equal(t, "cat", msg.Caption)
+
+	calls = srv.CallsTo("sendPhoto")
+	require.Len(t, calls, 1)
+	assert.Equal(t, []byte("image"), calls[0].Files["photo"].Data)
+
+	reader, err := b.GetFile(&msg.Photo.File)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(reader)
+	reader.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "image", string(data))
+
+	_, err = b.FileByID("unknown")
+	assert.True(t, tb.IsBadRequest(err))
+
+	srv.Reset()
+	assert.Empty(t, srv.Calls())
+}
+
+func TestServerResults(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	b, err := srv.NewBot(tb.Settings{Synchronous: true})
+	require.NoError(t, err)
+
+	chat := &tb.Chat{ID: -1001}
+	stored := srv.AddFile("stored.jpg", []byte("stored"))
+
+	msgs, err := b.SendAlbum(chat, tb.Album{
+		&tb.Photo{File: tb.FromReader(strings.NewReader("image")), Caption: "first"},
+		&tb.Photo{File: stored},
+		&tb.Video{File: tb.FromURL("https://example.com/video.mp4")},
+	})
+	require.NoError(t, err)
+	require.Len(t, msgs, 3)
+	assert.Equal(t, "first", msgs[0].Caption)
+	assert.NotEmpty(t, msgs[0].AlbumID)
+	assert.Equal(t, msgs[0].AlbumID, msgs[2].AlbumID)
+	require.NotNil(t, msgs[0].Photo)
+	assert.Equal(t, 5, msgs[0].Photo.FileSize)
+	assert.Equal(t, stored.FileID, msgs[1].Photo.FileID)
+	require.NotNil(t, msgs[2].Video)
+
+	msg, err := b.Send(chat, &tb.Poll{
+		Type:     tb.PollRegular,
+		Question: "Why?",
+		Options:  []tb.PollOption{{Text: "Yes"}, {Text: "No"}},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, msg.Poll)
+
+	poll, err := b.StopPoll(msg)
+	require.NoError(t, err)
+	assert.True(t, poll.Closed)
+	assert.Len(t, poll.Options, 2)
+
+	member, err := b.ChatMemberOf(chat, &tb.User{ID: 7})
+	require.NoError(t, err)
+	assert.Equal(t, 7, member.User.ID)
+	assert.Equal(t, tb.Member, member.Role)
+
+	admins, err := b.AdminsOf(chat)
+	require.NoError(t, err)
+	assert.Len(t, admins, 1)
+
+	link, err := b.GetInviteLink(chat)
+	require.NoError(t, err)
+	assert.NotEmpty(t, link)
+}
+
+func TestServerFailures(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	b, err := srv.NewBot(tb.Settings{})
+	require.NoError(t, err)
+
+	srv.Forbid("sendMessage")
+	_, err = b.Send(tb.ChatID(1), "hello")
+	assert.True(t, errors.Is(err, tb.ErrBlockedByUser))
+
+	srv.Flood("sendMessage", 5)
+	_, err = b.Send(tb.ChatID(1), "hello")
+
+	var flood tb.FloodError
+	require.True(t, errors.As(err, &flood))
+	assert.Equal(t, 5, flood.RetryAfter)
+
+	srv.Fail("*", Failure{Code: 500, Description: "Internal Server Error"})
+	assert.Error(t, b.Notify(tb.ChatID(1), tb.Typing))
+	assert.NoError(t, b.Notify(tb.ChatID(1), tb.Typing))
+
+	srv.Handle("getChat", func(c Call) (interface{}, *Failure) {
+		return tb.Chat{ID: 1, Type: tb.ChatPrivate, Username: "user"}, nil
+	})
+	chat, err := b.ChatByID("1")
+	require.NoError(t, err)
+	assert.Equal(t, "user", chat.Username)
+
+	_, err = tb.NewBot(tb.Settings{URL: srv.URL, Token: "other"})
+	assert.True(t, errors.Is(err, tb.ErrUnauthorized))
+}
+
+func TestServerUpdates(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	b, err := srv.NewBot(tb.Settings{
+		Poller: &tb.LongPoller{Timeout: time.Second},
+	})
+	require.NoError(t, err)
+
+	texts := make(chan string, 2)
+	b.Handle(tb.OnText, func(c tb.Context) error {
+		texts <- c.Text()
+		return nil
+	})
+
+	go b.Start()
+	defer b.Stop()
+
+	chat := &tb.Chat{ID: 1, Type: tb.ChatPrivate}
+	srv.AddUpdate(tb.Update{Message: &tb.Message{ID: 1, Chat: chat, Text: "first"}})
+	srv.AddUpdate(tb.Update{Message: &tb.Message{ID: 2, Chat: chat, Text: "second"}})
+
+	var got []string
+	for len(got) < 2 {
+		select {
+		case text := <-texts:
+			got = append(got, text)
+		case <-time.After(5 * time.Second):
+			t.Fatal("update wasn't delivered")
+		}
+	}
+	assert.ElementsMatch(t, []string{"first", "second"}, got)
+}
@@ -19,8 +19,8 @@
 //				return
 //			}
 //
-//			b.Handle(tb.OnText, func(m *tb.Message) {
-//				b.Send(m.Sender, "hello world")
+//			b.Handle(tb.OnText, func(c tb.Context) error {
+//				return c.Send("hello world")
 //			})
 //
 //			b.Start()
@@ -35,6 +35,7 @@ var (
 	ErrUnsupportedWhat = errors.New("telebot: unsupported what argument")
 	ErrCouldNotUpdate  = errors.New("telebot: could not fetch new updates")
 	ErrTrueResult      = errors.New("telebot: result is True")
+	ErrBadContext      = errors.New("telebot: context does not contain the needed data")
 )
 
 const DefaultApiURL = "https://api.telegram.org"
@@ -2,7 +2,6 @@ package telebot
 
 import (
 	"encoding/json"
-	"fmt"
 	"log"
 	"net/http"
 	"strconv"
@@ -29,14 +28,44 @@ func (b *Bot) deferDebug() {
 	}
 }
 
-func (b *Bot) runHandler(handler func()) {
+// OnError passes the error returned from the handler to the
+// Settings.OnError callback. If there is no such callback set,
+// the error is reported the same way as recovered panics.
+func (b *Bot) OnError(err error, c Context) {
+	if b.onError != nil {
+		b.onError(err, c)
+	} else {
+		b.debug(err)
+	}
+}
+
+func (b *Bot) runHandler(h HandlerFunc, c Context) {
+	b.runHandlerThen(h, c, nil)
+}
+
+// runHandlerThen runs the handler the same way as runHandler and
+// calls then after it, even if the middleware hasn't passed the
+// update to the handler, or it has panicked.
+func (b *Bot) runHandlerThen(h HandlerFunc, c Context, then func()) {
+	id := c.Update().ID
+	b.handlerStarted(id)
+
 	f := func() {
+		defer b.handlerDone(id)
+		if then != nil {
+			defer then()
+		}
 		defer b.deferDebug()
-		handler()
+		if err := applyMiddleware(h, b.middleware...)(c); err != nil {
+			b.OnError(err, c)
+		}
 	}
-	if b.synchronous {
+	switch {
+	case b.synchronous:
 		f()
-	} else {
+	case b.workers != nil:
+		b.workers.run(c, f)
+	default:
 		go f()
 	}
 }
@@ -48,32 +77,60 @@ func wrapError(err error) error {
 
 // extractOk checks given result for error. If result is ok returns nil.
 // In other cases it extracts API error. If error is not presented
-// in errors.go, it will be prefixed with `unknown` keyword.
+// in errors.go, a new APIError is returned.
 func extractOk(data []byte) error {
-	match := errorRx.FindStringSubmatch(string(data))
-	if match == nil || len(match) < 3 {
+	var resp APIResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return wrapError(err)
+	}
+	if resp.Ok {
 		return nil
 	}
 
-	desc := match[2]
-	err := ErrByDescription(desc)
-
-	if err == nil {
-		code, _ := strconv.Atoi(match[1])
+	desc := resp.Description
+	params := resp.Parameters
 
-		switch code {
-		case http.StatusTooManyRequests:
-			retry, _ := strconv.Atoi(match[3])
-			err = FloodError{
-				APIError:   NewAPIError(429, desc),
-				RetryAfter: retry,
-			}
-		default:
-			err = fmt.Errorf("telegram unknown: %s (%d)", desc, code)
+	switch {
+	case params != nil && params.MigrateTo != 0:
+		apiErr, ok := ErrByDescription(desc).(*APIError)
+		if !ok {
+			apiErr = NewAPIError(resp.Code, desc)
+		}
+		return GroupMigratedError{
+			APIError:   apiErr,
+			MigratedTo: params.MigrateTo,
 		}
+	case resp.Code == http.StatusTooManyRequests:
+		var retry int
+		if params != nil {
+			retry = params.RetryAfter
+		}
+		return FloodError{
+			APIError:   NewAPIError(resp.Code, desc),
+			RetryAfter: retry,
+		}
+	}
+
+	if err := ErrByDescription(desc); err != nil {
+		return err
 	}
+	return NewAPIError(resp.Code, desc)
+}
 
-	return err
+// extractResponse is like extractOk, but takes the HTTP status
+// into account. If the response is not a Bot API one, like an error
+// page of a gateway, the error is made up from the status, so it
+// could be told whether the request can be retried.
+func extractResponse(status int, data []byte) error {
+	var resp struct {
+		Ok *bool `json:"ok"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil || resp.Ok == nil {
+		if status >= http.StatusMultipleChoices {
+			return NewAPIError(status, http.StatusText(status))
+		}
+	}
+	return extractOk(data)
 }
 
 // extractMessage extracts common Message result from given data.
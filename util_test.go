@@ -1,6 +1,8 @@
 package telebot
 
 import (
+	"errors"
+	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -19,6 +21,29 @@ func TestExtractOk(t *testing.T) {
 		APIError:   NewAPIError(429, "Too Many Requests: retry after 8"),
 		RetryAfter: 8,
 	}, extractOk(data))
+
+	// fields order doesn't matter, quotes are escaped
+	data = []byte(`{"description":"Bad Request: can't parse \"entities\"","error_code":400,"ok":false}`)
+	assert.EqualError(t, extractOk(data), `telegram: can't parse "entities" (400)`)
+
+	data = []byte(`{"ok":false,"error_code":400,"description":"Bad Request: group chat was upgraded to a supergroup chat","parameters":{"migrate_to_chat_id":-1001234}}`)
+	err := extractOk(data)
+	assert.True(t, errors.Is(err, ErrGroupMigrated))
+
+	var migrated GroupMigratedError
+	require.True(t, errors.As(err, &migrated))
+	assert.Equal(t, int64(-1001234), migrated.MigratedTo)
+
+	data = []byte(`{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`)
+	assert.True(t, errors.Is(extractOk(data), ErrChatNotFound))
+
+	assert.Error(t, extractOk([]byte(`<html>Bad Gateway</html>`)))
+
+	err = extractResponse(http.StatusBadGateway, []byte(`<html>Bad Gateway</html>`))
+	assert.Equal(t, NewAPIError(http.StatusBadGateway, "Bad Gateway"), err)
+	err = extractResponse(http.StatusGatewayTimeout, []byte(`{}`))
+	assert.Equal(t, http.StatusGatewayTimeout, errorCode(err))
+	assert.NoError(t, extractResponse(http.StatusOK, []byte(`{"ok":true,"result":true}`)))
 }
 
 func TestExtractMessage(t *testing.T) {
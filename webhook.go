@@ -1,11 +1,18 @@
 package telebot
 
 import (
-	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 // A WebhookTLS specifies the path to a key and a cert so the poller can open
@@ -35,8 +42,11 @@ type WebhookEndpoint struct {
 // not what you want (at least while developing). If you have a single instance of your
 // bot you should consider to use the LongPoller instead of a WebHook.
 //
-// You can also leave the Listen field empty. In this case it is up to the caller to
-// add the Webhook to a http-mux.
+// You can also leave the Listen field empty. In this case the poller only registers
+// the webhook with Telegram, and it is up to the caller to add the Webhook, which is
+// an http.Handler, to a http-mux. Requests received before the bot is started are
+// rejected with 503 status code, so Telegram will redeliver them. See WebhookMux
+// to serve several bots on one listener.
 //
 type Webhook struct {
 	Listen         string   `json:"url"`
@@ -52,8 +62,131 @@ type Webhook struct {
 	TLS      *WebhookTLS
 	Endpoint *WebhookEndpoint
 
+	// Dedup skips the updates Telegram redelivers,
+	// e.g. when the previous request has timed out.
+	Dedup Deduplicator `json:"-"`
+
+	// Ack defines when the webhook requests are acknowledged.
+	// Default: AckOnEnqueue
+	Ack WebhookAck `json:"-"`
+
+	// QueueTimeout limits the wait for a free slot in the Updates
+	// channel. Then the request is rejected with 503 status code,
+	// so Telegram redelivers the update later. Default: 10 seconds
+	QueueTimeout time.Duration `json:"-"`
+
+	// Reply makes the handler wait for the handlers of the update,
+	// as with AckAfterHandle, and send the call made with WebhookReply
+	// in the response. The updates are passed to the Updates channel,
+	// so the pollers wrapping the webhook, like MiddlewarePoller,
+	// see them as usual.
+	Reply bool `json:"-"`
+
+	// HandleTimeout limits the wait for the handlers with Reply
+	// or AckAfterHandle. After that, the request is acknowledged
+	// anyway, without a reply call. Default: 30 seconds
+	HandleTimeout time.Duration `json:"-"`
+
+	// SecretToken is sent to Telegram on SetWebhook, then every
+	// request must have it in the X-Telegram-Bot-Api-Secret-Token
+	// header. It's 1-256 characters: A-Z, a-z, 0-9, _ and -.
+	SecretToken string `json:"-"`
+
+	// SecretPath is appended to the webhook URL as the last
+	// path segment, and requests to other paths are rejected.
+	SecretPath string `json:"-"`
+
+	// MaxBodySize limits the size of the request body.
+	// Default: 1 MB
+	MaxBodySize int64 `json:"-"`
+
+	// AllowedIPs restricts the addresses requests are accepted
+	// from. Use TelegramIPRanges to accept Telegram requests only.
+	// The address of the connection is checked, so it doesn't
+	// work behind a proxy.
+	AllowedIPs []*net.IPNet `json:"-"`
+
+	mu   sync.RWMutex
 	dest chan<- Update
+	stop chan struct{}
 	bot  *Bot
+
+	statsMu sync.Mutex
+	stats   WebhookStats
+}
+
+// WebhookAck defines when the webhook requests are acknowledged.
+type WebhookAck int
+
+const (
+	// AckOnEnqueue acknowledges the request as soon as the
+	// update is passed to the Updates channel.
+	AckOnEnqueue WebhookAck = iota
+
+	// AckAfterHandle acknowledges the request after the update
+	// is processed and its handlers are done, so the update is
+	// redelivered if the bot crashes before that.
+	AckAfterHandle
+)
+
+// WebhookStats describes the load of the webhook.
+type WebhookStats struct {
+	// Queued is the number of updates in the Updates channel.
+	Queued int
+
+	// Capacity is the capacity of the Updates channel.
+	Capacity int
+
+	// Waiting is the number of requests waiting
+	// for a free slot in the Updates channel.
+	Waiting int
+
+	// Handling is the number of requests waiting
+	// for the handlers of their updates.
+	Handling int
+
+	// Accepted is the number of updates accepted since start.
+	Accepted uint64
+
+	// Rejected is the number of updates rejected with 503
+	// status code, since the queue was full or the poller
+	// was stopped.
+	Rejected uint64
+}
+
+// Stats returns the current load of the webhook.
+// It's safe to be called while serving.
+func (h *Webhook) Stats() WebhookStats {
+	h.mu.RLock()
+	dest := h.dest
+	h.mu.RUnlock()
+
+	h.statsMu.Lock()
+	stats := h.stats
+	h.statsMu.Unlock()
+
+	stats.Queued, stats.Capacity = len(dest), cap(dest)
+	return stats
+}
+
+func (h *Webhook) count(f func(s *WebhookStats)) {
+	h.statsMu.Lock()
+	f(&h.stats)
+	h.statsMu.Unlock()
+}
+
+func (h *Webhook) queueTimeout() time.Duration {
+	if h.QueueTimeout > 0 {
+		return h.QueueTimeout
+	}
+	return 10 * time.Second
+}
+
+func (h *Webhook) handleTimeout() time.Duration {
+	if h.HandleTimeout > 0 {
+		return h.HandleTimeout
+	}
+	return 30 * time.Second
 }
 
 func (h *Webhook) getFiles() map[string]File {
@@ -101,22 +234,119 @@ func (h *Webhook) getParams() map[string]string {
 	if h.Endpoint != nil {
 		params["url"] = h.Endpoint.PublicURL
 	}
+	if h.SecretPath != "" {
+		params["url"] = strings.TrimSuffix(params["url"], "/") + "/" + h.SecretPath
+	}
+	if h.SecretToken != "" {
+		params["secret_token"] = h.SecretToken
+	}
 	return params
 }
 
+// TelegramIPRanges are the networks Telegram sends webhook requests from.
+// See https://core.telegram.org/bots/webhooks#the-short-version
+var TelegramIPRanges = []*net.IPNet{
+	mustParseCIDR("149.154.160.0/20"),
+	mustParseCIDR("91.108.4.0/22"),
+}
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// validate checks the request, and writes the error
+// response, if it's not a valid Telegram request.
+func (h *Webhook) validate(w http.ResponseWriter, r *http.Request) bool {
+	if len(h.AllowedIPs) > 0 && !h.allowedIP(r.RemoteAddr) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return false
+	}
+
+	if h.SecretPath != "" && !strings.HasSuffix(r.URL.Path, "/"+h.SecretPath) {
+		http.NotFound(w, r)
+		return false
+	}
+
+	if h.SecretToken != "" {
+		token := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(h.SecretToken)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return false
+		}
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+
+	if typ, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); typ != "application/json" {
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return false
+	}
+
+	return true
+}
+
+func (h *Webhook) allowedIP(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range h.AllowedIPs {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Webhook) maxBodySize() int64 {
+	if h.MaxBodySize > 0 {
+		return h.MaxBodySize
+	}
+	return 1 << 20
+}
+
+// Poll sets the webhook and serves it, unless the webhook is
+// mounted. If the webhook can't be set or served, the poller
+// returns, and Start returns the error.
 func (h *Webhook) Poll(b *Bot, dest chan Update, stop chan struct{}) {
 	if err := b.SetWebhook(h); err != nil {
-		b.debug(err)
-		close(stop)
+		b.pollerFailed(err)
 		return
 	}
 
 	// store the variables so the HTTP-handler can use 'em
+	h.mu.Lock()
 	h.dest = dest
 	h.bot = b
+	h.stop = stop
+	h.mu.Unlock()
+
+	// once stopped, the requests are answered with 503,
+	// so Telegram delivers the updates again later
+	defer func() {
+		h.mu.Lock()
+		h.dest = nil
+		h.bot = nil
+		h.stop = nil
+		h.mu.Unlock()
+	}()
 
 	if h.Listen == "" {
-		h.waitForStop(stop)
+		<-stop
 		return
 	}
 
@@ -125,33 +355,258 @@ func (h *Webhook) Poll(b *Bot, dest chan Update, stop chan struct{}) {
 		Handler: h,
 	}
 
-	go func(stop chan struct{}) {
-		h.waitForStop(stop)
-		s.Shutdown(context.Background())
-	}(stop)
+	served := make(chan error, 1)
+	go func() {
+		if h.TLS != nil {
+			served <- s.ListenAndServeTLS(h.TLS.Cert, h.TLS.Key)
+		} else {
+			served <- s.ListenAndServe()
+		}
+	}()
 
-	if h.TLS != nil {
-		s.ListenAndServeTLS(h.TLS.Cert, h.TLS.Key)
-	} else {
-		s.ListenAndServe()
+	select {
+	case err := <-served:
+		if err != http.ErrServerClosed {
+			b.pollerFailed(err)
+		}
+	case <-stop:
+		// requests which are still being served won't block
+		// on the channel, since they listen to stop as well
+		if err := s.Shutdown(b.shutdownContext()); err != nil {
+			b.debug(err)
+			s.Close()
+		}
 	}
 }
 
-func (h *Webhook) waitForStop(stop chan struct{}) {
-	<-stop
-	close(stop)
-}
-
 // The handler simply reads the update from the body of the requests
 // and writes them to the update channel.
+//
+// Requests are validated first: they must be POST requests with
+// a JSON body, which is not too large, matching the secrets and
+// the allowed IPs, if such are set.
+//
+// When the poller is stopped, or the Updates channel stays full
+// for QueueTimeout, it responds with 503 status code, so Telegram
+// will redeliver the update later.
 func (h *Webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	b, dest, stop := h.bot, h.dest, h.stop
+	h.mu.RUnlock()
+
+	if b == nil {
+		http.Error(w, "not running", http.StatusServiceUnavailable)
+		return
+	}
+	if !h.validate(w, r) {
+		return
+	}
+
+	max := h.maxBodySize()
+	if r.ContentLength > max {
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, max+1))
+	if err != nil {
+		b.debug(fmt.Errorf("cannot read update: %v", err))
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if int64(len(data)) > max {
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
 	var update Update
-	err := json.NewDecoder(r.Body).Decode(&update)
+	if err := json.Unmarshal(data, &update); err != nil {
+		b.debug(fmt.Errorf("cannot decode update: %v", err))
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	// acknowledge the redelivered update
+	if b.duplicate(h.Dedup, update) {
+		return
+	}
+
+	var (
+		handled <-chan struct{}
+		reply   *webhookReply
+	)
+	if h.Reply {
+		reply = &webhookReply{}
+		b.expectReply(update.ID, reply)
+		// the update may be filtered out, so it's not processed
+		defer b.takeReply(update.ID)
+	}
+	if h.Ack == AckAfterHandle || h.Reply {
+		handled = b.track(update.ID)
+	}
+
+	if !h.enqueue(dest, stop, update) {
+		if handled != nil {
+			b.untrack(update.ID)
+		}
+		b.forget(h.Dedup, update)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	if handled != nil {
+		h.wait(handled, stop)
+	}
+	if reply != nil {
+		h.reply(w, b, reply)
+	}
+}
+
+// enqueue passes the update to the Updates channel. It returns
+// false if the channel stays full for too long, or the poller
+// is stopped.
+func (h *Webhook) enqueue(dest chan<- Update, stop chan struct{}, update Update) bool {
+	// the poller may be stopped after the request is received
+	select {
+	case <-stop:
+		h.count(func(s *WebhookStats) { s.Rejected++ })
+		return false
+	default:
+	}
+
+	select {
+	case dest <- update:
+		h.count(func(s *WebhookStats) { s.Accepted++ })
+		return true
+	default:
+	}
+
+	h.count(func(s *WebhookStats) { s.Waiting++ })
+	defer h.count(func(s *WebhookStats) { s.Waiting-- })
+
+	timer := time.NewTimer(h.queueTimeout())
+	defer timer.Stop()
+
+	select {
+	case dest <- update:
+		h.count(func(s *WebhookStats) { s.Accepted++ })
+		return true
+	case <-timer.C:
+	case <-stop:
+	}
+
+	h.count(func(s *WebhookStats) { s.Rejected++ })
+	return false
+}
+
+// wait blocks until the handlers are done, the
+// timeout is reached or the poller is stopped.
+func (h *Webhook) wait(done <-chan struct{}, stop chan struct{}) {
+	h.count(func(s *WebhookStats) { s.Handling++ })
+	defer h.count(func(s *WebhookStats) { s.Handling-- })
+
+	timer := time.NewTimer(h.handleTimeout())
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-timer.C:
+	case <-stop:
+	}
+}
+
+// reply writes the call made with WebhookReply in the response.
+// Once the response is written, the calls are made as usual, if
+// the handlers are still running.
+func (h *Webhook) reply(w http.ResponseWriter, b *Bot, reply *webhookReply) {
+	body, err := reply.body()
 	if err != nil {
-		h.bot.debug(fmt.Errorf("cannot decode update: %v", err))
+		b.debug(err)
+		return
+	}
+	if body != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}
+}
+
+// WebhookMux serves the webhooks of several bots on one listener.
+// Requests to /bot/<id>/... are passed to the webhook added with
+// the id, the others are rejected with 404 status code.
+//
+// Example:
+//
+//		mux := tb.NewWebhookMux("https://example.com")
+//
+//		for id, b := range bots {
+//			hook := &tb.Webhook{SecretToken: secret}
+//			mux.Handle(id, hook)
+//			b.Poller = hook
+//			go b.Start()
+//		}
+//
+//		http.ListenAndServe(":8080", mux)
+//
+type WebhookMux struct {
+	publicURL string
+
+	mu    sync.RWMutex
+	hooks map[string]*Webhook
+}
+
+// NewWebhookMux creates a mux served at the public URL.
+// If it's empty, webhook endpoints are left untouched.
+func NewWebhookMux(publicURL string) *WebhookMux {
+	return &WebhookMux{
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		hooks:     make(map[string]*Webhook),
+	}
+}
+
+// Handle adds the webhook under the id, which must be a single
+// path segment. If the webhook has no Endpoint, it's set to the
+// public URL of the mux, so the bot registers the right URL.
+func (m *WebhookMux) Handle(id string, h *Webhook) {
+	if h.Endpoint == nil && m.publicURL != "" {
+		h.Endpoint = &WebhookEndpoint{
+			PublicURL: m.publicURL + "/bot/" + id + "/",
+		}
+	}
+
+	m.mu.Lock()
+	m.hooks[id] = h
+	m.mu.Unlock()
+}
+
+// Remove removes the webhook with the id.
+func (m *WebhookMux) Remove(id string) {
+	m.mu.Lock()
+	delete(m.hooks, id)
+	m.mu.Unlock()
+}
+
+// ServeHTTP routes the request to the webhook by its id.
+func (m *WebhookMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/bot/")
+	if path == r.URL.Path {
+		http.NotFound(w, r)
+		return
+	}
+
+	id := path
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		id = path[:i]
+	}
+
+	m.mu.RLock()
+	h, ok := m.hooks[id]
+	m.mu.RUnlock()
+
+	if !ok {
+		http.NotFound(w, r)
 		return
 	}
-	h.dest <- update
+	h.ServeHTTP(w, r)
 }
 
 // GetWebhook returns current webhook status.
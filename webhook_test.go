@@ -0,0 +1,431 @@
+package telebot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookParams(t *testing.T) {
+	h := &Webhook{
+		Listen:      "example.com:8443",
+		TLS:         &WebhookTLS{},
+		SecretPath:  "secret",
+		SecretToken: "token",
+	}
+
+	params := h.getParams()
+	assert.Equal(t, "https://example.com:8443/secret", params["url"])
+	assert.Equal(t, "token", params["secret_token"])
+
+	h.Endpoint = &WebhookEndpoint{PublicURL: "https://example.com/bot/"}
+	assert.Equal(t, "https://example.com/bot/secret", h.getParams()["url"])
+}
+
+func TestWebhookValidation(t *testing.T) {
+	b, err := NewBot(Settings{offline: true})
+	require.NoError(t, err)
+
+	dest := make(chan Update, 10)
+
+	h := &Webhook{
+		SecretPath:  "secret",
+		SecretToken: "token",
+		MaxBodySize: 64,
+		AllowedIPs:  TelegramIPRanges,
+		dest:        dest,
+		stop:        make(chan struct{}),
+		bot:         b,
+	}
+
+	request := func(modify func(r *http.Request)) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/hook/secret", strings.NewReader(`{"update_id":1}`))
+		r.RemoteAddr = "149.154.167.10:443"
+		r.Header.Set("Content-Type", "application/json; charset=utf-8")
+		r.Header.Set("X-Telegram-Bot-Api-Secret-Token", "token")
+		if modify != nil {
+			modify(r)
+		}
+		return r
+	}
+
+	tests := []struct {
+		name   string
+		modify func(r *http.Request)
+		code   int
+	}{
+		{"valid", nil, http.StatusOK},
+		{"ip", func(r *http.Request) { r.RemoteAddr = "10.0.0.1:443" }, http.StatusForbidden},
+		{"path", func(r *http.Request) { r.URL.Path = "/hook/other" }, http.StatusNotFound},
+		{"token", func(r *http.Request) { r.Header.Set("X-Telegram-Bot-Api-Secret-Token", "wrong") }, http.StatusUnauthorized},
+		{"no token", func(r *http.Request) { r.Header.Del("X-Telegram-Bot-Api-Secret-Token") }, http.StatusUnauthorized},
+		{"method", func(r *http.Request) { r.Method = http.MethodGet }, http.StatusMethodNotAllowed},
+		{"content type", func(r *http.Request) { r.Header.Set("Content-Type", "text/plain") }, http.StatusUnsupportedMediaType},
+		{"malformed", func(r *http.Request) {
+			r.Body = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{`)).Body
+			r.ContentLength = -1
+		}, http.StatusBadRequest},
+		{"too large", func(r *http.Request) {
+			body := `{"update_id":1,"message":{"text":"` + strings.Repeat("a", 64) + `"}}`
+			r.Body = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)).Body
+			r.ContentLength = -1
+		}, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, request(tt.modify))
+			assert.Equal(t, tt.code, w.Code)
+		})
+	}
+
+	assert.Len(t, dest, 1)
+}
+
+func TestWebhookMux(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		urls []string
+	)
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params map[string]string
+		json.NewDecoder(r.Body).Decode(&params)
+
+		mu.Lock()
+		urls = append(urls, params["url"])
+		mu.Unlock()
+
+		w.Write([]byte(`{"ok":true,"result":true}`))
+	}))
+	defer api.Close()
+
+	mux := NewWebhookMux("https://example.com/")
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	post := func(path string) int {
+		resp, err := http.Post(srv.URL+path, "application/json", strings.NewReader(`{"update_id":1,"message":{"text":"hi"}}`))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	got := make(chan string, 2)
+	for _, id := range []string{"first", "second"} {
+		b, err := NewBot(Settings{
+			URL:     api.URL,
+			Client:  api.Client(),
+			offline: true,
+		})
+		require.NoError(t, err)
+
+		id := id
+		b.Handle(OnText, func(c Context) error {
+			got <- id
+			return nil
+		})
+
+		hook := &Webhook{}
+		mux.Handle(id, hook)
+
+		// not started yet
+		assert.Equal(t, http.StatusServiceUnavailable, post("/bot/"+id+"/"))
+
+		b.Poller = hook
+		go b.Start()
+		defer b.Stop()
+	}
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(urls) == 2
+	}, time.Second, time.Millisecond)
+	assert.ElementsMatch(t, []string{
+		"https://example.com/bot/first/",
+		"https://example.com/bot/second/",
+	}, urls)
+
+	require.Eventually(t, func() bool {
+		return post("/bot/second/") == http.StatusOK
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, "second", <-got)
+
+	assert.Equal(t, http.StatusOK, post("/bot/first"))
+	assert.Equal(t, "first", <-got)
+
+	assert.Equal(t, http.StatusNotFound, post("/bot/third/"))
+	assert.Equal(t, http.StatusNotFound, post("/first/"))
+
+	mux.Remove("first")
+	assert.Equal(t, http.StatusNotFound, post("/bot/first/"))
+}
+
+func TestWebhookAck(t *testing.T) {
+	b, err := NewBot(Settings{offline: true})
+	require.NoError(t, err)
+
+	serve := func(h *Webhook, body string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	t.Run("queue", func(t *testing.T) {
+		dest := make(chan Update, 1)
+		h := &Webhook{
+			QueueTimeout: 20 * time.Millisecond,
+			dest:         dest,
+			stop:         make(chan struct{}),
+			bot:          b,
+		}
+
+		assert.Equal(t, http.StatusOK, serve(h, `{"update_id":1}`))
+
+		code := make(chan int)
+		go func() { code <- serve(h, `{"update_id":2}`) }()
+
+		require.Eventually(t, func() bool {
+			return h.Stats().Waiting == 1
+		}, time.Second, time.Millisecond)
+
+		assert.Equal(t, http.StatusServiceUnavailable, <-code)
+		assert.Equal(t, WebhookStats{
+			Queued:   1,
+			Capacity: 1,
+			Accepted: 1,
+			Rejected: 1,
+		}, h.Stats())
+	})
+
+	t.Run("handle", func(t *testing.T) {
+		release := make(chan struct{})
+		b.Handle(OnText, func(c Context) error {
+			<-release
+			return nil
+		})
+
+		stop := make(chan struct{})
+		defer close(stop)
+
+		dest := make(chan Update)
+		go func() {
+			for {
+				select {
+				case upd := <-dest:
+					b.ProcessUpdate(upd)
+				case <-stop:
+					return
+				}
+			}
+		}()
+
+		h := &Webhook{
+			Ack:  AckAfterHandle,
+			dest: dest,
+			stop: make(chan struct{}),
+			bot:  b,
+		}
+
+		code := make(chan int)
+		go func() { code <- serve(h, `{"update_id":3,"message":{"text":"hi","chat":{"id":1}}}`) }()
+
+		require.Eventually(t, func() bool {
+			return h.Stats().Handling == 1
+		}, time.Second, time.Millisecond)
+
+		select {
+		case <-code:
+			t.Fatal("acknowledged before the handler is done")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		close(release)
+		assert.Equal(t, http.StatusOK, <-code)
+		assert.Equal(t, 0, h.Stats().Handling)
+
+		// updates without handlers are acknowledged as well
+		assert.Equal(t, http.StatusOK, serve(h, `{"update_id":4}`))
+	})
+}
+
+func TestWebhookShutdown(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok":true,"result":true}`))
+	}))
+	defer api.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	h := &Webhook{Listen: addr, Reply: true, HandleTimeout: time.Minute}
+	b, err := NewBot(Settings{
+		URL:     api.URL,
+		Client:  api.Client(),
+		Poller:  h,
+		offline: true,
+	})
+	require.NoError(t, err)
+
+	release := make(chan struct{})
+	defer close(release)
+	b.Handle(OnText, func(c Context) error {
+		<-release
+		return nil
+	})
+
+	started := make(chan struct{})
+	go func() {
+		b.Start()
+		close(started)
+	}()
+
+	var conn net.Conn
+	require.Eventually(t, func() bool {
+		conn, err = net.Dial("tcp", addr)
+		return err == nil
+	}, time.Second, time.Millisecond)
+	defer conn.Close()
+
+	// the request is never finished, so the connection stays active
+	_, err = conn.Write([]byte("POST / HTTP/1.1\r\nHost: localhost\r\n"))
+	require.NoError(t, err)
+
+	code := make(chan int, 1)
+	go func() {
+		resp, err := http.Post("http://"+addr, "application/json",
+			strings.NewReader(`{"update_id":1,"message":{"text":"hi","chat":{"id":1}}}`))
+		if err != nil {
+			code <- 0
+			return
+		}
+		resp.Body.Close()
+		code <- resp.StatusCode
+	}()
+
+	require.Eventually(t, func() bool {
+		return h.Stats().Handling == 1
+	}, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err = b.Shutdown(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("poller has outlived the shutdown deadline")
+	}
+	assert.Equal(t, http.StatusOK, <-code)
+}
+
+func TestWebhookMountedShutdown(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok":true,"result":true}`))
+	}))
+	defer api.Close()
+
+	h := &Webhook{}
+	b, err := NewBot(Settings{
+		URL:     api.URL,
+		Client:  api.Client(),
+		Poller:  h,
+		offline: true,
+	})
+	require.NoError(t, err)
+
+	got := make(chan string, 2)
+	b.Handle(OnText, func(c Context) error {
+		got <- c.Text()
+		return nil
+	})
+
+	serve := func(text string) int {
+		r := httptest.NewRequest(http.MethodPost, "/",
+			strings.NewReader(`{"update_id":1,"message":{"text":"`+text+`"}}`))
+		r.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	started := make(chan struct{})
+	go func() {
+		b.Start()
+		close(started)
+	}()
+
+	require.Eventually(t, func() bool {
+		return serve("before") == http.StatusOK
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, "before", <-got)
+
+	_, err = b.Shutdown(context.Background())
+	require.NoError(t, err)
+	<-started
+
+	assert.Equal(t, http.StatusServiceUnavailable, serve("after"))
+	assert.Empty(t, got)
+}
+
+func TestWebhookFailure(t *testing.T) {
+	t.Run("set", func(t *testing.T) {
+		api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"ok":false,"error_code":401,"description":"Unauthorized"}`))
+		}))
+		defer api.Close()
+
+		b, err := NewBot(Settings{
+			URL:     api.URL,
+			Client:  api.Client(),
+			Poller:  &Webhook{},
+			offline: true,
+		})
+		require.NoError(t, err)
+
+		err = b.Start()
+		assert.True(t, errors.Is(err, ErrUnauthorized))
+	})
+
+	t.Run("listen", func(t *testing.T) {
+		api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"ok":true,"result":true}`))
+		}))
+		defer api.Close()
+
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer l.Close()
+
+		b, err := NewBot(Settings{
+			URL:     api.URL,
+			Client:  api.Client(),
+			Poller:  &Webhook{Listen: l.Addr().String()},
+			offline: true,
+		})
+		require.NoError(t, err)
+
+		// the port is already in use
+		assert.Error(t, b.Start())
+	})
+}
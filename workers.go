@@ -0,0 +1,154 @@
+package telebot
+
+import "sync"
+
+// Ordering defines which handlers must be run one after another,
+// in the order of incoming updates.
+type Ordering int
+
+const (
+	// OrderNone lets any handlers run in parallel.
+	OrderNone Ordering = iota
+
+	// OrderByChat serializes handlers of updates from the same chat.
+	// Updates without a chat are ordered by their sender.
+	OrderByChat
+
+	// OrderByUser serializes handlers of updates from the same user.
+	// Updates without a sender are ordered by their chat.
+	OrderByUser
+)
+
+// key returns the identifier handlers are serialized by.
+// If ok is false, the handler can be run by any worker.
+func (o Ordering) key(c Context) (key int64, ok bool) {
+	chat, sender := c.Chat(), c.Sender()
+
+	switch o {
+	case OrderByChat:
+		if chat != nil {
+			return chat.ID, true
+		}
+		if sender != nil {
+			return int64(sender.ID), true
+		}
+	case OrderByUser:
+		if sender != nil {
+			return int64(sender.ID), true
+		}
+		if chat != nil {
+			return chat.ID, true
+		}
+	}
+
+	return 0, false
+}
+
+// workerPool is a fixed-size set of goroutines running handlers.
+//
+// Every worker has its own queue, so handlers with the same
+// ordering key are always run by the same worker sequentially.
+// Handlers without a key are taken by any free worker.
+//
+// The workers are started on the first handler, and exit
+// when the pool is stopped, see Bot.Shutdown. The next
+// handler starts them again.
+type workerPool struct {
+	size     int
+	capacity int
+	ordering Ordering
+
+	mu      sync.RWMutex
+	running bool
+	quit    chan struct{}
+	done    *sync.WaitGroup
+	shared  chan func()
+	queues  []chan func()
+}
+
+func newWorkerPool(size, capacity int, ordering Ordering) *workerPool {
+	return &workerPool{
+		size:     size,
+		capacity: capacity,
+		ordering: ordering,
+	}
+}
+
+// run schedules f to be run by one of the workers. It blocks
+// if the queue of the chosen worker is full.
+func (p *workerPool) run(c Context, f func()) {
+	p.mu.RLock()
+	for !p.running {
+		p.mu.RUnlock()
+		p.mu.Lock()
+		if !p.running {
+			p.start()
+		}
+		p.mu.Unlock()
+		p.mu.RLock()
+	}
+	defer p.mu.RUnlock()
+
+	key, ok := p.ordering.key(c)
+	if !ok {
+		p.shared <- f
+		return
+	}
+
+	p.queues[uint64(key)%uint64(p.size)] <- f
+}
+
+func (p *workerPool) start() {
+	p.running = true
+	p.quit = make(chan struct{})
+	p.done = &sync.WaitGroup{}
+	p.shared = make(chan func(), p.capacity)
+	p.queues = make([]chan func(), p.size)
+
+	p.done.Add(p.size)
+	for i := range p.queues {
+		p.queues[i] = make(chan func(), p.capacity)
+		go p.work(p.queues[i], p.shared, p.quit, p.done)
+	}
+}
+
+// stop makes the workers exit once the queued handlers
+// are done, and waits for them.
+func (p *workerPool) stop() {
+	p.mu.Lock()
+	if !p.running {
+		p.mu.Unlock()
+		return
+	}
+
+	p.running = false
+	close(p.quit)
+	done := p.done
+	p.mu.Unlock()
+
+	done.Wait()
+}
+
+func (p *workerPool) work(queue, shared chan func(), quit chan struct{}, done *sync.WaitGroup) {
+	defer done.Done()
+
+	for {
+		select {
+		case f := <-queue:
+			f()
+		case f := <-shared:
+			f()
+		case <-quit:
+			for {
+				select {
+				case f := <-queue:
+					f()
+				case f := <-shared:
+					f()
+				default:
+					return
+				}
+			}
+		}
+	}
+}
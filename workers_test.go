@@ -0,0 +1,149 @@
+package telebot
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkers(t *testing.T) {
+	const workers = 3
+
+	b, err := NewBot(Settings{Workers: workers, offline: true})
+	require.NoError(t, err)
+
+	var (
+		wg              sync.WaitGroup
+		running, maxRun int32
+	)
+
+	b.Handle(OnText, func(m *Message) {
+		defer wg.Done()
+
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+
+		for {
+			max := atomic.LoadInt32(&maxRun)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRun, max, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	})
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		b.ProcessUpdate(Update{Message: &Message{Text: "text", Chat: &Chat{ID: int64(i)}}})
+	}
+	wg.Wait()
+
+	assert.True(t, maxRun > 1)
+	assert.True(t, maxRun <= workers)
+}
+
+func TestWorkersShutdown(t *testing.T) {
+	b, err := NewBot(Settings{Workers: 2, Ordering: OrderByChat, offline: true})
+	require.NoError(t, err)
+
+	var handled int32
+	b.Handle(OnText, func(c Context) error {
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&handled, 1)
+		return nil
+	})
+
+	for i := 0; i < 10; i++ {
+		b.ProcessUpdate(Update{Message: &Message{Text: "text", Chat: &Chat{ID: 1}}})
+	}
+
+	_, err = b.Shutdown(context.Background())
+	require.NoError(t, err)
+
+	// queued handlers are done before the workers exit
+	assert.Equal(t, int32(10), atomic.LoadInt32(&handled))
+	assert.False(t, b.workers.running)
+
+	// the workers are started again
+	b.ProcessUpdate(Update{Message: &Message{Text: "text", Chat: &Chat{ID: 1}}})
+	_, err = b.Shutdown(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int32(11), atomic.LoadInt32(&handled))
+}
+
+func TestOrdering(t *testing.T) {
+	b, err := NewBot(Settings{
+		Workers:  4,
+		Ordering: OrderByChat,
+		offline:  true,
+	})
+	require.NoError(t, err)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		got     = make(map[int64][]int)
+		chats   = []int64{-100, 1, 2, 3, 4, 5}
+		perChat = 10
+	)
+
+	b.Handle(OnText, func(m *Message) {
+		defer wg.Done()
+		// the first messages are the slowest ones
+		time.Sleep(time.Duration(perChat-m.ID) * time.Millisecond)
+
+		mu.Lock()
+		got[m.Chat.ID] = append(got[m.Chat.ID], m.ID)
+		mu.Unlock()
+	})
+
+	for i := 0; i < perChat; i++ {
+		for _, chat := range chats {
+			wg.Add(1)
+			b.ProcessUpdate(Update{Message: &Message{
+				ID:   i,
+				Text: "text",
+				Chat: &Chat{ID: chat},
+			}})
+		}
+	}
+	wg.Wait()
+
+	for _, chat := range chats {
+		require.Len(t, got[chat], perChat)
+		for i, id := range got[chat] {
+			assert.Equal(t, i, id)
+		}
+	}
+}
+
+func TestOrderingKey(t *testing.T) {
+	b, err := NewBot(Settings{offline: true})
+	require.NoError(t, err)
+
+	msg := b.NewContext(Update{Message: &Message{Chat: &Chat{ID: 1}, Sender: &User{ID: 2}}})
+	query := b.NewContext(Update{Query: &Query{From: User{ID: 2}}})
+	poll := b.NewContext(Update{Poll: &Poll{}})
+
+	key, ok := OrderByChat.key(msg)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), key)
+
+	key, ok = OrderByUser.key(msg)
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), key)
+
+	key, ok = OrderByChat.key(query)
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), key)
+
+	_, ok = OrderByChat.key(poll)
+	assert.False(t, ok)
+	_, ok = OrderNone.key(msg)
+	assert.False(t, ok)
+}